@@ -4,4 +4,8 @@ const (
 	SignatureHeader          = "X-Signature"
 	SignatureTimestampHeader = "X-Signature-Timestamp"
 	PublicKeyHeader          = "X-Public-Key"
+	CorrelationIDHeader      = "X-Correlation-Id"
+	RetryAfterHeader         = "Retry-After"
+	IdempotencyKeyHeader     = "X-Idempotency-Key"
+	RequestIDHeader          = "X-Request-Id"
 )
@@ -0,0 +1,68 @@
+// Package http3 serves and calls the T-ZERO Network services over HTTP/3
+// (QUIC), with the quic-go implementation. It is kept apart so that the
+// provider and network packages do not depend on QUIC.
+//
+// Example:
+//
+//	server, err := provider.StartServer(handler,
+//	    provider.WithTLSCertFiles(certPath, keyPath),
+//	    http3.WithServer(),
+//	)
+//
+//	client, err := network.NewClient(privateKey, http3.WithTransport())
+package http3
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/t-0-network/provider-sdk-go/network"
+	"github.com/t-0-network/provider-sdk-go/provider"
+)
+
+var ErrTLSRequired = errors.New("HTTP/3 requires TLS to be configured")
+
+// WithServer also serves HTTP/3 over QUIC, on the UDP port with the same
+// number as the TCP port of the server, see provider.WithUDPServer.
+// Responses over TCP announce it with an Alt-Svc header.
+//
+// HTTP/3 requires TLS and a TCP address. 0-RTT data is refused, as it could
+// be replayed.
+func WithServer() provider.ServerOption {
+	return provider.WithUDPServer(newServer)
+}
+
+// WithTransport sends the calls over HTTP/3 instead of HTTP/1.1 or HTTP/2, to
+// servers started with WithServer. There is no fallback to TCP. The TLS
+// options, such as network.WithRootCAs, apply to the QUIC connections.
+func WithTransport() network.ClientOption {
+	return network.WithTransportFactory(func(tlsConfig *tls.Config) http.RoundTripper {
+		return &http3.Transport{TLSClientConfig: tlsConfig}
+	})
+}
+
+func newServer(handler http.Handler, tlsConfig *tls.Config) (provider.UDPServer, error) {
+	if tlsConfig == nil {
+		return nil, ErrTLSRequired
+	}
+
+	return &server{Server: &http3.Server{
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+		QUICConfig: &quic.Config{
+			Allow0RTT: false,
+		},
+	}}, nil
+}
+
+// server adapts the HTTP/3 server to provider.UDPServer.
+type server struct {
+	*http3.Server
+}
+
+func (s *server) SetHeaders(header http.Header) error {
+	return s.SetQUICHeaders(header)
+}
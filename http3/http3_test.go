@@ -0,0 +1,198 @@
+package http3_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/crypto"
+	"github.com/t-0-network/provider-sdk-go/http3"
+	"github.com/t-0-network/provider-sdk-go/network"
+	"github.com/t-0-network/provider-sdk-go/provider"
+)
+
+// newTestCertificate returns a self-signed certificate for 127.0.0.1.
+func newTestCertificate(t *testing.T, serial int64) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "provider"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+// certProvider records the client certificate seen by the handler.
+type certProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+	certs chan *provider.ClientCertificateInfo
+}
+
+func (p *certProvider) PayOut(
+	ctx context.Context, _ *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	info, _ := provider.ClientCertificateFromContext(ctx)
+	p.certs <- info
+	return connect.NewResponse(&payment.PayoutResponse{}), nil
+}
+
+type fixture struct {
+	addr       string
+	privateKey network.PrivateKeyHexed
+	roots      *x509.CertPool
+	clientCert tls.Certificate
+	clientLeaf *x509.Certificate
+	svc        *certProvider
+}
+
+// startServer starts a provider server serving HTTP/3, and requiring a
+// client certificate.
+func startServer(t *testing.T, opts ...provider.ServerOption) *fixture {
+	t.Helper()
+
+	key, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	svc := &certProvider{certs: make(chan *provider.ClientCertificateInfo, 1)}
+	handler, err := provider.NewHttpHandler(provider.NetworkPublicKeyHexed(crypto.HexPublicKey(key.PubKey())),
+		provider.Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc)),
+	)
+	require.NoError(t, err)
+
+	serverCert, serverLeaf := newTestCertificate(t, 1)
+	clientCert, clientLeaf := newTestCertificate(t, 2)
+	roots, clientCAs := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(serverLeaf)
+	clientCAs.AddCert(clientLeaf)
+
+	srv, err := provider.StartServer(handler, append([]provider.ServerOption{
+		provider.WithAddr("127.0.0.1:0"),
+		provider.WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{serverCert}}),
+		provider.WithClientCAs(clientCAs),
+		http3.WithServer(),
+	}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+
+	return &fixture{
+		addr:       srv.Addr().String(),
+		privateKey: network.PrivateKeyHexed(crypto.HexPrivateKey(key)),
+		roots:      roots,
+		clientCert: clientCert,
+		clientLeaf: clientLeaf,
+		svc:        svc,
+	}
+}
+
+func (f *fixture) payOut(t *testing.T, opts ...network.ClientOption) error {
+	t.Helper()
+
+	client, err := network.NewServiceClient(f.privateKey, paymentconnect.NewProviderServiceClient,
+		append([]network.ClientOption{
+			network.WithBaseURL("https://" + f.addr),
+			network.WithRootCAs(f.roots),
+			network.WithClientCertificate(f.clientCert),
+		}, opts...)...,
+	)
+	require.NoError(t, err)
+
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	return err
+}
+
+func TestWithServer_ServesSignedRequests(t *testing.T) {
+	f := startServer(t)
+
+	require.NoError(t, f.payOut(t, http3.WithTransport()))
+
+	// The client certificate of the QUIC connection reaches the handler
+	info := <-f.svc.certs
+	require.NotNil(t, info)
+	assert.Equal(t, provider.SPKIHash(f.clientLeaf), info.SPKIHash)
+
+	// TCP is still served
+	require.NoError(t, f.payOut(t))
+	<-f.svc.certs
+}
+
+func TestWithServer_VerifiesSignature(t *testing.T) {
+	f := startServer(t)
+
+	otherKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+	signFn, err := crypto.NewSignerFromHex(crypto.HexPrivateKey(otherKey))
+	require.NoError(t, err)
+
+	err = f.payOut(t, http3.WithTransport(), network.WithSignatureFunction(signFn))
+	assert.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+	assert.Empty(t, f.svc.certs, "the handler must not be called")
+}
+
+func TestWithServer_AnnouncesAltSvc(t *testing.T) {
+	f := startServer(t)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      f.roots,
+		Certificates: []tls.Certificate{f.clientCert},
+	}}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("https://" + f.addr)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, port, err := net.SplitHostPort(f.addr)
+	require.NoError(t, err)
+	assert.Contains(t, resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`)
+}
+
+func TestWithServer_AppliesAllowedCIDRs(t *testing.T) {
+	f := startServer(t, provider.WithAllowedCIDRs(netip.MustParsePrefix("127.0.0.0/8")))
+	require.NoError(t, f.payOut(t, http3.WithTransport()))
+	<-f.svc.certs
+
+	f = startServer(t, provider.WithAllowedCIDRs(netip.MustParsePrefix("192.0.2.0/24")))
+	err := f.payOut(t, http3.WithTransport(), network.WithTimeout(500*time.Millisecond))
+	assert.Error(t, err)
+	assert.Empty(t, f.svc.certs, "the handler must not be called")
+}
+
+func TestWithServer_RequiresTLS(t *testing.T) {
+	srv, err := provider.StartServer(http.NotFoundHandler(), provider.WithAddr("127.0.0.1:0"), http3.WithServer())
+	assert.ErrorIs(t, err, http3.ErrTLSRequired)
+	assert.Nil(t, srv)
+
+	server, err := provider.BuildServer(http.NotFoundHandler(), http3.WithServer())
+	assert.ErrorIs(t, err, provider.ErrStartServerOnly)
+	assert.Nil(t, server)
+}
@@ -0,0 +1,15 @@
+// Package correlation generates the correlation IDs shared by the provider
+// server and the network client.
+package correlation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID returns a random correlation ID, as 32 hexadecimal characters.
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,25 @@
+// Package metrics holds the Prometheus helpers shared by the provider server
+// and the network client.
+package metrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterOrReuse registers the collector, or returns the collector already
+// registered with the same descriptor, so several servers or clients sharing
+// a registerer record to the same metrics.
+func RegisterOrReuse[T prometheus.Collector](registerer prometheus.Registerer, collector T) (T, error) {
+	if err := registerer.Register(collector); err != nil {
+		var alreadyRegistered prometheus.AlreadyRegisteredError
+		if errors.As(err, &alreadyRegistered) {
+			if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return collector, err
+	}
+	return collector, nil
+}
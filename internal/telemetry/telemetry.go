@@ -0,0 +1,21 @@
+// Package telemetry holds the OpenTelemetry helpers shared by the provider
+// server and the network client.
+package telemetry
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// ProcedureAttributes splits a procedure such as
+// "/tzero.v1.payment.NetworkService/UpdateQuote" into RPC semantic attributes.
+func ProcedureAttributes(procedure string) []attribute.KeyValue {
+	service, method, _ := strings.Cut(strings.TrimPrefix(procedure, "/"), "/")
+	return []attribute.KeyValue{
+		semconv.RPCSystemConnectRPC,
+		semconv.RPCService(service),
+		semconv.RPCMethod(method),
+	}
+}
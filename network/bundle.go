@@ -0,0 +1,90 @@
+package network
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/provider/providerconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/recipient/recipientconnect"
+)
+
+// Client bundles the clients of the T-ZERO Network services. They share the
+// signed transport, the interceptors, such as the metrics, retries and circuit
+// breaker, and the options passed to NewClient.
+type Client struct {
+	// Payment is the network service of pay-out providers.
+	Payment paymentconnect.NetworkServiceClient
+	// PaymentIntent is the payment intent service of the network.
+	PaymentIntent payment_intentconnect.PaymentIntentServiceClient
+	// PaymentIntentProvider is the network service of pay-in providers.
+	PaymentIntentProvider providerconnect.NetworkServiceClient
+	// PaymentIntentRecipient is the network service of payment intent recipients.
+	PaymentIntentRecipient recipientconnect.NetworkServiceClient
+
+	config *clientConfig
+	closed atomic.Bool
+}
+
+// NewClient creates the clients of all the network services, see Client. It
+// accepts the same options as NewServiceClient.
+//
+// Example:
+//
+//	client, err := network.NewClient(privateKey, network.WithRetryPolicy(network.RetryPolicy{}))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer client.Close()
+//
+//	_, err = client.Payment.UpdateQuote(ctx, connect.NewRequest(quote))
+func NewClient(privateKey PrivateKeyHexed, opts ...ClientOption) (*Client, error) {
+	config, err := newClientConfig(privateKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{config: config}
+	// Calls are rejected once the client is closed
+	config.httpClient.Transport = closedGuard{next: config.httpClient.Transport, closed: &c.closed}
+
+	httpClient, baseURL, connectOptions := config.httpClient, config.baseURL, config.connectOptions
+	c.Payment = paymentconnect.NewNetworkServiceClient(httpClient, baseURL, connectOptions...)
+	c.PaymentIntent = payment_intentconnect.NewPaymentIntentServiceClient(httpClient, baseURL, connectOptions...)
+	c.PaymentIntentProvider = providerconnect.NewNetworkServiceClient(httpClient, baseURL, connectOptions...)
+	c.PaymentIntentRecipient = recipientconnect.NewNetworkServiceClient(httpClient, baseURL, connectOptions...)
+
+	return c, nil
+}
+
+// Close releases the connections of the client. Calls made afterwards fail
+// with ErrClientClosed. Shared transports, http.DefaultTransport and the ones
+// given with WithBaseTransport or WithHTTPClient, are left open.
+func (c *Client) Close() error {
+	if !c.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	switch transport := c.config.ownTransport.(type) {
+	case io.Closer:
+		return transport.Close()
+	case interface{ CloseIdleConnections() }:
+		transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// closedGuard rejects the requests of a closed client.
+type closedGuard struct {
+	next   http.RoundTripper
+	closed *atomic.Bool
+}
+
+func (g closedGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	if g.closed.Load() {
+		return nil, ErrClientClosed
+	}
+	return g.next.RoundTrip(req)
+}
@@ -0,0 +1,66 @@
+package network_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+	"github.com/t-0-network/provider-sdk-go/provider"
+)
+
+func TestNetworkClient_SharesOptionsAcrossServices(t *testing.T) {
+	privateKey, publicKey := newTestKey(t)
+	baseURL := startNetwork(t, publicKey,
+		(&flakyNetwork{}).service(),
+		provider.Handler(payment_intentconnect.NewPaymentIntentServiceHandler,
+			payment_intentconnect.PaymentIntentServiceHandler(payment_intentconnect.UnimplementedPaymentIntentServiceHandler{}),
+		),
+	)
+
+	registry := prometheus.NewRegistry()
+	client, err := network.NewClient(privateKey,
+		network.WithBaseURL(baseURL),
+		network.WithPrometheusRegisterer(registry),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = client.Payment.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	require.NoError(t, err)
+
+	// Passed the signature verification, but is not implemented
+	_, err = client.PaymentIntent.UpdateQuote(ctx, connect.NewRequest(&payment_intent.UpdateQuoteRequest{}))
+	assert.Equal(t, connect.CodeUnimplemented, connect.CodeOf(err))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	procedures := make(map[string]bool)
+	for _, family := range families {
+		if family.GetName() != "tzero_network_client_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "procedure" {
+					procedures[label.GetValue()] = true
+				}
+			}
+		}
+	}
+	assert.True(t, procedures[paymentconnect.NetworkServiceUpdateQuoteProcedure])
+	assert.True(t, procedures[payment_intentconnect.PaymentIntentServiceUpdateQuoteProcedure])
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close())
+
+	_, err = client.Payment.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	assert.ErrorIs(t, err, network.ErrClientClosed)
+}
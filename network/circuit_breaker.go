@@ -0,0 +1,256 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// Default values of the CircuitBreakerPolicy fields
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitOpenTimeout      = 30 * time.Second
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets calls through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails calls fast, until the open timeout elapses.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe call through, whose result closes or
+	// opens the circuit again.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(s))
+	}
+}
+
+// CircuitScope selects what a circuit breaker tracks.
+type CircuitScope int
+
+const (
+	// CircuitPerHost shares a circuit between the procedures of a host.
+	CircuitPerHost CircuitScope = iota
+	// CircuitPerProcedure gives every procedure its own circuit.
+	CircuitPerProcedure
+)
+
+// CircuitBreakerPolicy configures the circuit breaker, see WithCircuitBreaker.
+// Zero fields take the default values.
+type CircuitBreakerPolicy struct {
+	// Scope selects whether circuits are kept per host or per procedure.
+	Scope CircuitScope
+	// FailureThreshold is the number of consecutive failed calls opening the circuit.
+	FailureThreshold int
+	// OpenTimeout is the time an open circuit fails calls before probing.
+	OpenTimeout time.Duration
+	// OnStateChange is called when a circuit changes state, with the host or
+	// the procedure it tracks, e.g. to pause quote publishing.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+// CircuitOpenError is the cause of the CodeUnavailable error of calls
+// rejected by an open circuit.
+type CircuitOpenError struct {
+	// Key is the host or the procedure of the circuit.
+	Key string
+	// RetryAt is when the circuit lets a probe call through.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open until %s", e.Key, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// WithCircuitBreaker fails calls fast while the network is failing. After
+// FailureThreshold consecutive calls fail with CodeUnavailable,
+// CodeDeadlineExceeded, CodeResourceExhausted, CodeInternal or CodeUnknown,
+// the circuit opens and calls fail immediately with a CodeUnavailable error
+// wrapping a *CircuitOpenError. After OpenTimeout, a single probe call is let
+// through, closing the circuit if it succeeds. The results of the calls
+// started before the circuit last changed state are ignored, so a slow call
+// started while closed neither closes an open circuit nor extends its
+// OpenTimeout.
+//
+// Every attempt of WithRetryPolicy goes through the circuit breaker, and calls
+// rejected by an open circuit are not retried.
+func WithCircuitBreaker(policy CircuitBreakerPolicy) ClientOption {
+	return func(c *clientOptions) {
+		policy = policy.withDefaults()
+		c.circuitBreaker = &policy
+	}
+}
+
+func (p CircuitBreakerPolicy) withDefaults() CircuitBreakerPolicy {
+	if p.FailureThreshold <= 0 {
+		p.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if p.OpenTimeout <= 0 {
+		p.OpenTimeout = DefaultCircuitOpenTimeout
+	}
+	return p
+}
+
+// circuitBreaker holds the circuits of a client.
+type circuitBreaker struct {
+	policy  CircuitBreakerPolicy
+	timeNow func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	// generation counts the state changes, the result of a call started
+	// before the current state began is ignored.
+	generation uint64
+}
+
+func (c *circuit) setState(state CircuitState) {
+	c.state = state
+	c.generation++
+}
+
+func newCircuitBreaker(policy CircuitBreakerPolicy) *circuitBreaker {
+	return &circuitBreaker{
+		policy:   policy,
+		timeNow:  time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+func (b *circuitBreaker) interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			key := req.Peer().Addr
+			if b.policy.Scope == CircuitPerProcedure {
+				key = req.Spec().Procedure
+			}
+
+			generation, err := b.allow(key)
+			if err != nil {
+				return nil, connect.NewError(connect.CodeUnavailable, err)
+			}
+			res, err := next(ctx, req)
+			b.record(key, generation, err)
+			return res, err
+		}
+	}
+}
+
+// allow returns a *CircuitOpenError if the call must be rejected, or the
+// generation of the circuit the call is let through in.
+func (b *circuitBreaker) allow(key string) (uint64, error) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+
+	from := c.state
+	switch c.state {
+	case CircuitOpen:
+		retryAt := c.openedAt.Add(b.policy.OpenTimeout)
+		if b.timeNow().Before(retryAt) {
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Key: key, RetryAt: retryAt}
+		}
+		c.setState(CircuitHalfOpen)
+		c.probing = true
+	case CircuitHalfOpen:
+		if c.probing {
+			b.mu.Unlock()
+			return 0, &CircuitOpenError{Key: key, RetryAt: b.timeNow()}
+		}
+		c.probing = true
+	}
+	to, generation := c.state, c.generation
+	b.mu.Unlock()
+
+	b.notify(key, from, to)
+	return generation, nil
+}
+
+// record updates the circuit with the result of a call let through in the
+// given generation. The circuit is open, or has changed state, since a call
+// of an older generation started, which says nothing about the current state:
+// its result is ignored. In the half-open state, only the probe is let
+// through, and its result closes or opens the circuit again.
+func (b *circuitBreaker) record(key string, generation uint64, err error) {
+	b.mu.Lock()
+	c := b.circuits[key]
+	if generation != c.generation {
+		b.mu.Unlock()
+		return
+	}
+	from := c.state
+
+	switch {
+	case circuitFailure(err):
+		c.failures++
+		if c.state == CircuitHalfOpen || c.failures >= b.policy.FailureThreshold {
+			c.setState(CircuitOpen)
+			c.openedAt = b.timeNow()
+		}
+	case connect.CodeOf(err) == connect.CodeCanceled:
+		// Says nothing about the server, only a probe has to be let through again
+	default:
+		if c.state != CircuitClosed {
+			c.setState(CircuitClosed)
+		}
+		c.failures = 0
+	}
+	if from == CircuitHalfOpen {
+		c.probing = false
+	}
+	to := c.state
+	b.mu.Unlock()
+
+	b.notify(key, from, to)
+}
+
+func (b *circuitBreaker) notify(key string, from, to CircuitState) {
+	if from != to && b.policy.OnStateChange != nil {
+		b.policy.OnStateChange(key, from, to)
+	}
+}
+
+// circuitFailure reports whether err shows the server is failing, rather than
+// rejecting the request.
+func circuitFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch connect.CodeOf(err) {
+	case connect.CodeUnavailable, connect.CodeDeadlineExceeded, connect.CodeResourceExhausted,
+		connect.CodeInternal, connect.CodeUnknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,191 @@
+package network_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// stateChanges records the transitions reported by a circuit breaker.
+type stateChanges struct {
+	mu      sync.Mutex
+	changes []string
+}
+
+func (s *stateChanges) record(key string, from, to network.CircuitState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, key+": "+from.String()+" -> "+to.String())
+}
+
+func (s *stateChanges) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changes
+}
+
+func unavailableErrors(n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = connect.NewError(connect.CodeUnavailable, errors.New("unavailable"))
+	}
+	return errs
+}
+
+func TestWithCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	svc := &flakyNetwork{errs: unavailableErrors(3)}
+	changes := &stateChanges{}
+	client := startFlakyNetwork(t, svc, network.WithCircuitBreaker(network.CircuitBreakerPolicy{
+		Scope:            network.CircuitPerProcedure,
+		FailureThreshold: 2,
+		OpenTimeout:      100 * time.Millisecond,
+		OnStateChange:    changes.record,
+	}))
+
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+
+	// Open, the network is not called
+	err := updateQuote(client)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	var openErr *network.CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	assert.ErrorIs(t, err, network.ErrCircuitOpen)
+	assert.Equal(t, paymentconnect.NetworkServiceUpdateQuoteProcedure, openErr.Key)
+	assert.Len(t, svc.attempts(), 2)
+
+	// The failing probe opens the circuit again
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+	assert.ErrorIs(t, updateQuote(client), network.ErrCircuitOpen)
+	assert.Len(t, svc.attempts(), 3)
+
+	// The succeeding probe closes it
+	time.Sleep(150 * time.Millisecond)
+	require.NoError(t, updateQuote(client))
+	require.NoError(t, updateQuote(client))
+
+	procedure := paymentconnect.NetworkServiceUpdateQuoteProcedure
+	assert.Equal(t, []string{
+		procedure + ": closed -> open",
+		procedure + ": open -> half-open",
+		procedure + ": half-open -> open",
+		procedure + ": open -> half-open",
+		procedure + ": half-open -> closed",
+	}, changes.get())
+}
+
+func TestWithCircuitBreaker_IgnoresRejectedRequests(t *testing.T) {
+	svc := &flakyNetwork{errs: []error{
+		connect.NewError(connect.CodeInvalidArgument, errors.New("invalid")),
+		connect.NewError(connect.CodeInvalidArgument, errors.New("invalid")),
+	}}
+	client := startFlakyNetwork(t, svc, network.WithCircuitBreaker(network.CircuitBreakerPolicy{
+		FailureThreshold: 1,
+	}))
+
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(updateQuote(client)))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(updateQuote(client)))
+	require.NoError(t, updateQuote(client))
+}
+
+func TestWithCircuitBreaker_NotRetried(t *testing.T) {
+	svc := &flakyNetwork{errs: unavailableErrors(5)}
+	client := startFlakyNetwork(t, svc,
+		network.WithRetryPolicy(network.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}),
+		network.WithCircuitBreaker(network.CircuitBreakerPolicy{FailureThreshold: 2}),
+	)
+
+	err := updateQuote(client)
+	assert.ErrorIs(t, err, network.ErrCircuitOpen)
+	assert.Len(t, svc.attempts(), 2)
+}
+
+type heldCallKey struct{}
+
+// heldCalls holds the calls made with a heldCallKey context before they reach
+// the network, until released.
+type heldCalls struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newHeldCalls() *heldCalls {
+	return &heldCalls{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (h *heldCalls) option() network.ClientOption {
+	return network.WithConnectOptions(connect.WithInterceptors(connect.UnaryInterceptorFunc(
+		func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				if ctx.Value(heldCallKey{}) != nil {
+					h.started <- struct{}{}
+					<-h.release
+				}
+				return next(ctx, req)
+			}
+		},
+	)))
+}
+
+// hold starts a held call, returning its error once released.
+func (h *heldCalls) hold(client paymentconnect.NetworkServiceClient) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		ctx := context.WithValue(context.Background(), heldCallKey{}, true)
+		_, err := client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+		result <- err
+	}()
+	<-h.started
+	return result
+}
+
+func TestWithCircuitBreaker_IgnoresStaleSuccess(t *testing.T) {
+	svc := &flakyNetwork{errs: unavailableErrors(1)}
+	held := newHeldCalls()
+	changes := &stateChanges{}
+	client := startFlakyNetwork(t, svc, held.option(), network.WithCircuitBreaker(network.CircuitBreakerPolicy{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Hour,
+		OnStateChange:    changes.record,
+	}))
+
+	// Started while closed, succeeds once the circuit is open
+	result := held.hold(client)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+	close(held.release)
+	require.NoError(t, <-result)
+
+	assert.ErrorIs(t, updateQuote(client), network.ErrCircuitOpen)
+	assert.Len(t, changes.get(), 1)
+}
+
+func TestWithCircuitBreaker_IgnoresStaleFailure(t *testing.T) {
+	svc := &flakyNetwork{errs: unavailableErrors(2)}
+	held := newHeldCalls()
+	client := startFlakyNetwork(t, svc, held.option(), network.WithCircuitBreaker(network.CircuitBreakerPolicy{
+		FailureThreshold: 1,
+		OpenTimeout:      200 * time.Millisecond,
+	}))
+
+	// Started while closed, fails once the circuit is open, which must not
+	// delay the probe
+	result := held.hold(client)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+	time.Sleep(100 * time.Millisecond)
+	close(held.release)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(<-result))
+
+	time.Sleep(150 * time.Millisecond)
+	require.NoError(t, updateQuote(client))
+	assert.Len(t, svc.attempts(), 3)
+}
@@ -4,7 +4,6 @@ package network
 import (
 	"fmt"
 	"net/http"
-	"time"
 
 	"connectrpc.com/connect"
 	"github.com/t-0-network/provider-sdk-go/crypto"
@@ -17,36 +16,105 @@ type ClientFactory[T any] func(httpClient connect.HTTPClient, baseURL string, op
 func NewServiceClient[T any](
 	privateKey PrivateKeyHexed, clientFactory ClientFactory[T], opts ...ClientOption,
 ) (T, error) {
+	config, err := newClientConfig(privateKey, opts)
+	if err != nil {
+		var t T
+		return t, err
+	}
+
+	return clientFactory(config.httpClient, config.baseURL, config.connectOptions...), nil
+}
+
+// clientConfig is shared by the service clients created from the same options.
+type clientConfig struct {
+	httpClient     *http.Client
+	ownTransport   http.RoundTripper // nil if the transport is given or shared
+	baseURL        string
+	connectOptions []connect.ClientOption
+}
+
+func newClientConfig(privateKey PrivateKeyHexed, opts []ClientOption) (*clientConfig, error) {
 	options := defaultClientOptions
 	for _, opt := range opts {
 		opt(&options)
 	}
 
-	var t T
-
 	if err := options.validate(); err != nil {
-		return t, fmt.Errorf("validating client options: %w", err)
+		return nil, fmt.Errorf("validating client options: %w", err)
 	}
 
 	if options.signFn == nil {
 		if privateKey == "" {
-			return t, ErrEmptyPrivateKey
+			return nil, ErrEmptyPrivateKey
 		}
 
 		defaultSignFn, err := crypto.NewSignerFromHex(string(privateKey))
 		if err != nil {
-			return t, fmt.Errorf("creating signer from hexed private key: %w", err)
+			return nil, fmt.Errorf("creating signer from hexed private key: %w", err)
 		}
 
 		options.signFn = defaultSignFn
 	}
 
-	client := http.Client{
-		Timeout: options.timeout,
-		Transport: NewSigningTransport(
-			options.signFn, time.Now,
-		),
+	client, ownTransport, err := newHTTPClient(&options)
+	if err != nil {
+		return nil, fmt.Errorf("creating HTTP client: %w", err)
+	}
+
+	connectOptions := options.connectOptions
+	if options.circuitBreaker != nil {
+		// Inside the retries, so that every attempt is accounted for
+		connectOptions = append(
+			[]connect.ClientOption{connect.WithInterceptors(newCircuitBreaker(*options.circuitBreaker).interceptor())},
+			connectOptions...,
+		)
+	}
+	if options.rateLimit != nil {
+		// Outside the circuit breaker, which must not count rate limited calls as failures
+		var metrics *rateLimitMetrics
+		if options.registerer != nil {
+			if metrics, err = newRateLimitMetrics(options.registerer); err != nil {
+				return nil, fmt.Errorf("registering rate limit metrics: %w", err)
+			}
+		}
+		connectOptions = append(
+			[]connect.ClientOption{connect.WithInterceptors(newRateLimiter(*options.rateLimit, metrics).interceptor())},
+			connectOptions...,
+		)
+	}
+	if options.retryPolicy != nil {
+		// Inside the metrics and telemetry interceptors, which observe the whole call
+		connectOptions = append(
+			[]connect.ClientOption{connect.WithInterceptors(retryInterceptor(*options.retryPolicy))},
+			connectOptions...,
+		)
+	}
+	if options.registerer != nil {
+		metrics, err := newClientMetrics(options.registerer)
+		if err != nil {
+			return nil, fmt.Errorf("registering client metrics: %w", err)
+		}
+		connectOptions = append(
+			[]connect.ClientOption{connect.WithInterceptors(metrics.interceptor())},
+			connectOptions...,
+		)
+	}
+	if telemetry := newClientTelemetry(options.telemetry); telemetry != nil {
+		connectOptions = append(
+			[]connect.ClientOption{connect.WithInterceptors(telemetry.interceptor())},
+			connectOptions...,
+		)
 	}
+	// Outermost, so that every interceptor sees the correlation ID
+	connectOptions = append(
+		[]connect.ClientOption{connect.WithInterceptors(correlationInterceptor())},
+		connectOptions...,
+	)
 
-	return clientFactory(&client, options.baseURL, options.connectOptions...), nil
+	return &clientConfig{
+		httpClient:     client,
+		ownTransport:   ownTransport,
+		baseURL:        options.baseURL,
+		connectOptions: connectOptions,
+	}, nil
 }
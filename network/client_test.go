@@ -0,0 +1,120 @@
+package network_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/crypto"
+	"github.com/t-0-network/provider-sdk-go/network"
+	"github.com/t-0-network/provider-sdk-go/provider"
+)
+
+// newTestKey generates the key pair of a provider calling the network.
+func newTestKey(t *testing.T) (network.PrivateKeyHexed, provider.NetworkPublicKeyHexed) {
+	t.Helper()
+
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	return network.PrivateKeyHexed(crypto.HexPrivateKey(privateKey)),
+		provider.NetworkPublicKeyHexed(crypto.HexPublicKey(privateKey.PubKey()))
+}
+
+// newNetworkHandler acts as the network, serving the given services and
+// verifying the signatures of the provider public key.
+func newNetworkHandler(t *testing.T, publicKey provider.NetworkPublicKeyHexed, services ...provider.BuildHandler) http.Handler {
+	t.Helper()
+
+	handler, err := provider.NewHttpHandler(publicKey, services...)
+	require.NoError(t, err)
+	return handler
+}
+
+// startNetwork serves the given services over HTTP, and returns the base URL
+// of the network.
+func startNetwork(t *testing.T, publicKey provider.NetworkPublicKeyHexed, services ...provider.BuildHandler) string {
+	t.Helper()
+
+	srv := httptest.NewServer(newNetworkHandler(t, publicKey, services...))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+// flakyNetwork acts as the network, failing UpdateQuote with the queued errors
+// before succeeding.
+type flakyNetwork struct {
+	paymentconnect.UnimplementedNetworkServiceHandler
+
+	mu         sync.Mutex
+	errs       []error
+	timestamps []string
+	headers    []http.Header
+}
+
+func (n *flakyNetwork) UpdateQuote(
+	_ context.Context, req *connect.Request[payment.UpdateQuoteRequest],
+) (*connect.Response[payment.UpdateQuoteResponse], error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.timestamps = append(n.timestamps, req.Header().Get(common.SignatureTimestampHeader))
+	n.headers = append(n.headers, req.Header().Clone())
+	if len(n.errs) > 0 {
+		err := n.errs[0]
+		n.errs = n.errs[1:]
+		return nil, err
+	}
+	return connect.NewResponse(&payment.UpdateQuoteResponse{}), nil
+}
+
+func (n *flakyNetwork) attempts() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.timestamps
+}
+
+func (n *flakyNetwork) requestHeaders() []http.Header {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.headers
+}
+
+func (n *flakyNetwork) service() provider.BuildHandler {
+	return provider.Handler(paymentconnect.NewNetworkServiceHandler, paymentconnect.NetworkServiceHandler(n))
+}
+
+// startFlakyNetwork serves the network service, and returns a client of it.
+func startFlakyNetwork(
+	t *testing.T, svc *flakyNetwork, opts ...network.ClientOption,
+) paymentconnect.NetworkServiceClient {
+	t.Helper()
+
+	privateKey, publicKey := newTestKey(t)
+	baseURL := startNetwork(t, publicKey, svc.service())
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewNetworkServiceClient,
+		append([]network.ClientOption{network.WithBaseURL(baseURL)}, opts...)...,
+	)
+	require.NoError(t, err)
+	return client
+}
+
+func updateQuote(client paymentconnect.NetworkServiceClient) error {
+	_, err := client.UpdateQuote(context.Background(), connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	return err
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
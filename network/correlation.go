@@ -0,0 +1,101 @@
+package network
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/internal/correlation"
+)
+
+type correlationIDKey struct{}
+
+type idempotencyKeyKey struct{}
+
+// WithCorrelationID returns a context whose calls to the network carry the
+// given correlation ID, in the X-Correlation-Id header. Calls made without a
+// correlation ID get a generated one. Quote the correlation ID when reporting
+// an issue with a call to T-0.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, correlationID)
+}
+
+// CorrelationIDFromContext returns the correlation ID set by
+// WithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	correlationID, ok := ctx.Value(correlationIDKey{}).(string)
+	return correlationID, ok && correlationID != ""
+}
+
+// WithIdempotencyKey returns a context whose calls to the network carry the
+// given idempotency key, in the X-Idempotency-Key header, e.g. the
+// payment_client_id of a CreatePayment request. The key is a plain header,
+// it is not covered by the request signature, which only signs the body and
+// the timestamp: rely on TLS to protect it on the way to the network.
+//
+// Example:
+//
+//	ctx = network.WithIdempotencyKey(ctx, req.PaymentClientId)
+//	res, err := client.Payment.CreatePayment(ctx, connect.NewRequest(req))
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// ErrorCorrelationID returns the correlation ID of the call which failed with
+// the given error, or an empty string if the error does not come from a
+// network client.
+func ErrorCorrelationID(err error) string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return ""
+	}
+	return connectErr.Meta().Get(common.CorrelationIDHeader)
+}
+
+// ErrorRequestID returns the request ID assigned by the network to the call
+// which failed with the given error, from the X-Request-Id response header,
+// or an empty string if the network did not send one.
+func ErrorRequestID(err error) string {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return ""
+	}
+	return connectErr.Meta().Get(common.RequestIDHeader)
+}
+
+// correlationInterceptor sets the correlation ID and idempotency key headers
+// of every call, and adds the correlation ID to the metadata of the errors,
+// next to the headers sent by the network. All the attempts of a retried
+// call share the correlation ID.
+func correlationInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			correlationID, ok := CorrelationIDFromContext(ctx)
+			if !ok {
+				correlationID = req.Header().Get(common.CorrelationIDHeader)
+			}
+			if correlationID == "" {
+				correlationID = correlation.NewID()
+			}
+			req.Header().Set(common.CorrelationIDHeader, correlationID)
+
+			if key, ok := ctx.Value(idempotencyKeyKey{}).(string); ok && key != "" {
+				req.Header().Set(common.IdempotencyKeyHeader, key)
+			}
+
+			res, err := next(ctx, req)
+			if err != nil {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					connectErr = connect.NewError(connect.CodeUnknown, err)
+					err = connectErr
+				}
+				if connectErr.Meta().Get(common.CorrelationIDHeader) == "" {
+					connectErr.Meta().Set(common.CorrelationIDHeader, correlationID)
+				}
+			}
+			return res, err
+		}
+	}
+}
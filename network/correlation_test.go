@@ -0,0 +1,63 @@
+package network_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+func TestClient_CorrelationIDOnErrors(t *testing.T) {
+	failure := connect.NewError(connect.CodeUnavailable, errors.New("unavailable"))
+	failure.Meta().Set(common.RequestIDHeader, "req-42")
+	svc := &flakyNetwork{errs: []error{failure, failure}}
+	client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}))
+
+	err := updateQuote(client)
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	assert.Equal(t, "req-42", network.ErrorRequestID(err))
+
+	// All the attempts share the generated correlation ID returned with the error
+	correlationID := network.ErrorCorrelationID(err)
+	assert.Len(t, correlationID, 32)
+	headers := svc.requestHeaders()
+	require.Len(t, headers, 2)
+	for _, header := range headers {
+		assert.Equal(t, correlationID, header.Get(common.CorrelationIDHeader))
+	}
+
+	// Every call has its own
+	require.NoError(t, updateQuote(client))
+	headers = svc.requestHeaders()
+	assert.NotEqual(t, correlationID, headers[2].Get(common.CorrelationIDHeader))
+}
+
+func TestClient_CorrelationIDAndIdempotencyKeyFromContext(t *testing.T) {
+	svc := &flakyNetwork{errs: []error{connect.NewError(connect.CodeInvalidArgument, errors.New("invalid"))}}
+	client := startFlakyNetwork(t, svc)
+
+	ctx := network.WithCorrelationID(context.Background(), "ticket-1234")
+	ctx = network.WithIdempotencyKey(ctx, "payment-client-id")
+	_, err := client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	assert.Equal(t, "ticket-1234", network.ErrorCorrelationID(err))
+	assert.Empty(t, network.ErrorRequestID(err))
+
+	// The idempotency key is sent as is, next to the signature
+	_, err = client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	require.NoError(t, err)
+	headers := svc.requestHeaders()
+	require.Len(t, headers, 2)
+	assert.Equal(t, "ticket-1234", headers[1].Get(common.CorrelationIDHeader))
+	assert.Equal(t, "payment-client-id", headers[1].Get(common.IdempotencyKeyHeader))
+}
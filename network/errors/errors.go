@@ -0,0 +1,171 @@
+// Package errors converts the failures of the calls to the T-ZERO Network,
+// Connect errors and failure results of the responses alike, to typed Go
+// errors, to be matched with errors.Is and errors.As instead of switching over
+// codes and oneofs.
+//
+// Example:
+//
+//	res, err := errors.Result(client.Payment.CreatePayment(ctx, connect.NewRequest(req)))
+//	switch {
+//	case stderrors.Is(err, errors.ErrCreditOrPredepositRequired):
+//	    // settle before paying
+//	case errors.IsRetryable(err):
+//	    // try again later
+//	case err != nil:
+//	    return err
+//	}
+package errors
+
+import (
+	stderrors "errors"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// Errors of the failure results of the network responses, and of the failed
+// payments notified by UpdatePayment.
+var (
+	ErrQuoteNotFound                       = stderrors.New("quote not found")
+	ErrCreditOrPredepositRequired          = stderrors.New("credit or pre-deposit required")
+	ErrPaymentIntentRejected               = stderrors.New("payment intent rejected")
+	ErrConfirmationCodeMismatch            = stderrors.New("confirmation code mismatch")
+	ErrNoActiveQuote                       = stderrors.New("no active quote")
+	ErrManualAmlCheckRejected              = stderrors.New("manual AML check rejected")
+	ErrNoQuoteAfterAmlApproval             = stderrors.New("no quote after AML approval")
+	ErrQuoteRejectedAfterAmlApproval       = stderrors.New("quote rejected after AML approval")
+	ErrAmlRiskCheckFailed                  = stderrors.New("AML risk check failed")
+	ErrCreditLimitExceededAfterAmlApproval = stderrors.New("credit limit exceeded after AML approval")
+	ErrUnspecifiedFailure                  = stderrors.New("unspecified failure")
+)
+
+// Errors of the Connect codes.
+var (
+	ErrCanceled           = stderrors.New("canceled")
+	ErrUnknown            = stderrors.New("unknown")
+	ErrInvalidArgument    = stderrors.New("invalid argument")
+	ErrDeadlineExceeded   = stderrors.New("deadline exceeded")
+	ErrNotFound           = stderrors.New("not found")
+	ErrAlreadyExists      = stderrors.New("already exists")
+	ErrPermissionDenied   = stderrors.New("permission denied")
+	ErrResourceExhausted  = stderrors.New("resource exhausted")
+	ErrFailedPrecondition = stderrors.New("failed precondition")
+	ErrAborted            = stderrors.New("aborted")
+	ErrOutOfRange         = stderrors.New("out of range")
+	ErrUnimplemented      = stderrors.New("unimplemented")
+	ErrInternal           = stderrors.New("internal")
+	ErrUnavailable        = stderrors.New("unavailable")
+	ErrDataLoss           = stderrors.New("data loss")
+	ErrUnauthenticated    = stderrors.New("unauthenticated")
+)
+
+var codeErrors = map[connect.Code]error{
+	connect.CodeCanceled:           ErrCanceled,
+	connect.CodeUnknown:            ErrUnknown,
+	connect.CodeInvalidArgument:    ErrInvalidArgument,
+	connect.CodeDeadlineExceeded:   ErrDeadlineExceeded,
+	connect.CodeNotFound:           ErrNotFound,
+	connect.CodeAlreadyExists:      ErrAlreadyExists,
+	connect.CodePermissionDenied:   ErrPermissionDenied,
+	connect.CodeResourceExhausted:  ErrResourceExhausted,
+	connect.CodeFailedPrecondition: ErrFailedPrecondition,
+	connect.CodeAborted:            ErrAborted,
+	connect.CodeOutOfRange:         ErrOutOfRange,
+	connect.CodeUnimplemented:      ErrUnimplemented,
+	connect.CodeInternal:           ErrInternal,
+	connect.CodeUnavailable:        ErrUnavailable,
+	connect.CodeDataLoss:           ErrDataLoss,
+	connect.CodeUnauthenticated:    ErrUnauthenticated,
+}
+
+// Error is a failure of a call to the network. It matches its Kind with
+// errors.Is, and its Connect error, if any, with errors.As.
+type Error struct {
+	// Kind is one of the sentinel errors of the package.
+	Kind error
+	// Reason is the name of the failure reason of a failure result, as
+	// defined by the API, e.g. "REASON_QUOTE_NOT_FOUND". Empty for Connect
+	// errors.
+	Reason string
+	// Cause is the Connect error of a failed call, nil for failure results.
+	Cause error
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Cause.Error()
+	}
+	if e.Reason == "" {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + " (" + e.Reason + ")"
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// FromError converts the error of a call to the network to an *Error, by
+// its Connect code. Nil and *Error errors are returned as is.
+func FromError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var typedErr *Error
+	if stderrors.As(err, &typedErr) {
+		return err
+	}
+	return &Error{Kind: codeErrors[connect.CodeOf(err)], Cause: err}
+}
+
+// Result converts the outcome of a call to the network to a typed error: the
+// error of a failed call, or the failure result of its response, see
+// MessageError. The message of the response is returned only if the call
+// succeeded.
+//
+// Example:
+//
+//	quote, err := errors.Result(client.Payment.GetQuote(ctx, connect.NewRequest(req)))
+func Result[T any](res *connect.Response[T], err error) (*T, error) {
+	if err != nil {
+		return nil, FromError(err)
+	}
+	if err := MessageError(res.Msg); err != nil {
+		return nil, err
+	}
+	return res.Msg, nil
+}
+
+// IsRetryable reports whether the call which failed with err is worth
+// retrying as is, see network.IsRetryable. Failure results are not.
+func IsRetryable(err error) bool {
+	return network.IsRetryable(err)
+}
+
+// IsPermanent reports whether the call which failed with err will fail again,
+// whenever retried: the request is invalid, or the network rejected it for
+// good. The failures depending on the quotes or the credit, such as
+// ErrQuoteNotFound, may go away and are not permanent.
+func IsPermanent(err error) bool {
+	err = FromError(err)
+	for _, permanent := range []error{
+		ErrInvalidArgument,
+		ErrNotFound,
+		ErrAlreadyExists,
+		ErrFailedPrecondition,
+		ErrOutOfRange,
+		ErrUnimplemented,
+		ErrPaymentIntentRejected,
+		ErrConfirmationCodeMismatch,
+		ErrManualAmlCheckRejected,
+		ErrAmlRiskCheckFailed,
+	} {
+		if stderrors.Is(err, permanent) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package errors
+
+import (
+	stderrors "errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+func createPayment(res *payment.CreatePaymentResponse, err error) (*connect.Response[payment.CreatePaymentResponse], error) {
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(res), nil
+}
+
+func TestResult_ConnectErrors(t *testing.T) {
+	connectErr := connect.NewError(connect.CodeUnavailable, stderrors.New("unavailable"))
+	connectErr.Meta().Set(common.CorrelationIDHeader, "correlation-id")
+
+	res, err := Result(createPayment(nil, connectErr))
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, ErrUnavailable)
+	assert.True(t, IsRetryable(err))
+	assert.False(t, IsPermanent(err))
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	assert.Equal(t, "correlation-id", network.ErrorCorrelationID(err))
+	assert.Equal(t, connectErr.Error(), err.Error())
+
+	var typedErr *Error
+	require.ErrorAs(t, err, &typedErr)
+	assert.Equal(t, ErrUnavailable, typedErr.Kind)
+	assert.Same(t, connectErr, typedErr.Cause)
+
+	// Not converted twice
+	assert.Same(t, err, FromError(err))
+	assert.NoError(t, FromError(nil))
+
+	_, err = Result(createPayment(nil, connect.NewError(connect.CodeInvalidArgument, stderrors.New("invalid"))))
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	assert.False(t, IsRetryable(err))
+	assert.True(t, IsPermanent(err))
+
+	// Unconverted Connect errors are classified too
+	assert.True(t, IsPermanent(connect.NewError(connect.CodeNotFound, stderrors.New("not found"))))
+}
+
+func TestResult_FailureResults(t *testing.T) {
+	res, err := Result(createPayment(&payment.CreatePaymentResponse{
+		Result: &payment.CreatePaymentResponse_Failure_{Failure: &payment.CreatePaymentResponse_Failure{
+			Reason: payment.CreatePaymentResponse_Failure_REASON_CREDIT_OR_PREDEPOSIT_REQUIRED,
+		}},
+	}, nil))
+	assert.Nil(t, res)
+	assert.ErrorIs(t, err, ErrCreditOrPredepositRequired)
+	assert.EqualError(t, err, "credit or pre-deposit required (REASON_CREDIT_OR_PREDEPOSIT_REQUIRED)")
+	assert.False(t, IsRetryable(err))
+	assert.False(t, IsPermanent(err))
+	var connectErr *connect.Error
+	assert.False(t, stderrors.As(err, &connectErr))
+
+	accepted := &payment.CreatePaymentResponse{
+		PaymentClientId: "client-id",
+		Result:          &payment.CreatePaymentResponse_Accepted_{Accepted: &payment.CreatePaymentResponse_Accepted{}},
+	}
+	res, err = Result(createPayment(accepted, nil))
+	require.NoError(t, err)
+	assert.Same(t, accepted, res)
+}
+
+func TestMessageError(t *testing.T) {
+	tests := map[string]struct {
+		msg       any
+		kind      error
+		permanent bool
+	}{
+		"quote not found": {
+			msg: &payment.GetQuoteResponse{Result: &payment.GetQuoteResponse_Failure_{Failure: &payment.GetQuoteResponse_Failure{
+				Reason: payment.GetQuoteResponse_Failure_REASON_QUOTE_NOT_FOUND,
+			}}},
+			kind: ErrQuoteNotFound,
+		},
+		"unspecified failure": {
+			msg:  &payment.GetQuoteResponse{Result: &payment.GetQuoteResponse_Failure_{Failure: &payment.GetQuoteResponse_Failure{}}},
+			kind: ErrUnspecifiedFailure,
+		},
+		"manual AML check rejected": {
+			msg: &payment.CompleteManualAmlCheckResponse{Result: &payment.CompleteManualAmlCheckResponse_Rejected_{
+				Rejected: &payment.CompleteManualAmlCheckResponse_Rejected{},
+			}},
+			kind:      ErrManualAmlCheckRejected,
+			permanent: true,
+		},
+		"payment failed": {
+			msg: &payment.UpdatePaymentRequest{Result: &payment.UpdatePaymentRequest_Failed_{Failed: &payment.UpdatePaymentRequest_Failed{
+				Reason: payment.UpdatePaymentRequest_Failed_REASON_AML_RISK_CHECK_FAILED,
+			}}},
+			kind:      ErrAmlRiskCheckFailed,
+			permanent: true,
+		},
+		"indicative quote not found": {
+			msg: &payment_intent.GetQuoteResponse{Result: &payment_intent.GetQuoteResponse_QuoteNotFound_{
+				QuoteNotFound: &payment_intent.GetQuoteResponse_QuoteNotFound{},
+			}},
+			kind: ErrQuoteNotFound,
+		},
+		"payment intent rejected": {
+			msg: &payment_intent.CreatePaymentIntentResponse{Result: &payment_intent.CreatePaymentIntentResponse_Failure_{
+				Failure: &payment_intent.CreatePaymentIntentResponse_Failure{
+					Reason: payment_intent.CreatePaymentIntentResponse_Failure_FAILURE_REASON_REJECTED,
+				},
+			}},
+			kind:      ErrPaymentIntentRejected,
+			permanent: true,
+		},
+		"no active quote": {
+			msg: &payment_intent.ConfirmFundsReceivedResponse{Result: &payment_intent.ConfirmFundsReceivedResponse_Reject_{
+				Reject: &payment_intent.ConfirmFundsReceivedResponse_Reject{
+					Reason: payment_intent.ConfirmFundsReceivedResponse_Reject_REJECT_REASON_NO_ACTIVE_QUOTE,
+				},
+			}},
+			kind: ErrNoActiveQuote,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := MessageError(tt.msg)
+			assert.ErrorIs(t, err, tt.kind)
+			assert.Equal(t, tt.permanent, IsPermanent(err))
+		})
+	}
+
+	assert.NoError(t, MessageError(&payment.GetQuoteResponse{}))
+	assert.NoError(t, MessageError(&payment.UpdateQuoteResponse{}))
+}
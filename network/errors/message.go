@@ -0,0 +1,89 @@
+package errors
+
+import (
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+)
+
+var getQuoteReasons = map[payment.GetQuoteResponse_Failure_Reason]error{
+	payment.GetQuoteResponse_Failure_REASON_QUOTE_NOT_FOUND: ErrQuoteNotFound,
+}
+
+var createPaymentReasons = map[payment.CreatePaymentResponse_Failure_Reason]error{
+	payment.CreatePaymentResponse_Failure_REASON_QUOTE_NOT_FOUND:               ErrQuoteNotFound,
+	payment.CreatePaymentResponse_Failure_REASON_CREDIT_OR_PREDEPOSIT_REQUIRED: ErrCreditOrPredepositRequired,
+}
+
+var updatePaymentReasons = map[payment.UpdatePaymentRequest_Failed_Reason]error{
+	payment.UpdatePaymentRequest_Failed_REASON_NO_QUOTE_AFTER_AML_APPROVAL:              ErrNoQuoteAfterAmlApproval,
+	payment.UpdatePaymentRequest_Failed_REASON_QUOTE_REJECTED_AFTER_AML_APPROVAL:        ErrQuoteRejectedAfterAmlApproval,
+	payment.UpdatePaymentRequest_Failed_REASON_AML_RISK_CHECK_FAILED:                    ErrAmlRiskCheckFailed,
+	payment.UpdatePaymentRequest_Failed_REASON_CREDIT_LIMIT_EXCEEDED_AFTER_AML_APPROVAL: ErrCreditLimitExceededAfterAmlApproval,
+}
+
+var createPaymentIntentReasons = map[payment_intent.CreatePaymentIntentResponse_Failure_Reason]error{
+	payment_intent.CreatePaymentIntentResponse_Failure_FAILURE_REASON_QUOTE_NOT_FOUND: ErrQuoteNotFound,
+	payment_intent.CreatePaymentIntentResponse_Failure_FAILURE_REASON_REJECTED:        ErrPaymentIntentRejected,
+}
+
+var confirmFundsReceivedReasons = map[payment_intent.ConfirmFundsReceivedResponse_Reject_Reason]error{
+	payment_intent.ConfirmFundsReceivedResponse_Reject_REJECT_REASON_CONFIRMATION_CODE_MISMATCH: ErrConfirmationCodeMismatch,
+	payment_intent.ConfirmFundsReceivedResponse_Reject_REJECT_REASON_NO_ACTIVE_QUOTE:            ErrNoActiveQuote,
+}
+
+// MessageError returns the error of the failure result of a network message,
+// or nil if the message has none. The messages with a failure result are:
+//   - payment.GetQuoteResponse
+//   - payment.CreatePaymentResponse
+//   - payment.CompleteManualAmlCheckResponse
+//   - payment.UpdatePaymentRequest, for the failed payments notified to the
+//     provider
+//   - payment_intent.GetQuoteResponse
+//   - payment_intent.CreatePaymentIntentResponse
+//   - payment_intent.ConfirmFundsReceivedResponse
+func MessageError(msg any) error {
+	switch msg := msg.(type) {
+	case *payment.GetQuoteResponse:
+		if failure := msg.GetFailure(); failure != nil {
+			return reasonError(failure.GetReason(), getQuoteReasons)
+		}
+	case *payment.CreatePaymentResponse:
+		if failure := msg.GetFailure(); failure != nil {
+			return reasonError(failure.GetReason(), createPaymentReasons)
+		}
+	case *payment.CompleteManualAmlCheckResponse:
+		if msg.GetRejected() != nil {
+			return &Error{Kind: ErrManualAmlCheckRejected}
+		}
+	case *payment.UpdatePaymentRequest:
+		if failed := msg.GetFailed(); failed != nil {
+			return reasonError(failed.GetReason(), updatePaymentReasons)
+		}
+	case *payment_intent.GetQuoteResponse:
+		if msg.GetQuoteNotFound() != nil {
+			return &Error{Kind: ErrQuoteNotFound}
+		}
+	case *payment_intent.CreatePaymentIntentResponse:
+		if failure := msg.GetFailure(); failure != nil {
+			return reasonError(failure.GetReason(), createPaymentIntentReasons)
+		}
+	case *payment_intent.ConfirmFundsReceivedResponse:
+		if reject := msg.GetReject(); reject != nil {
+			return reasonError(reject.GetReason(), confirmFundsReceivedReasons)
+		}
+	}
+	return nil
+}
+
+// reasonError returns the error of a failure reason, ErrUnspecifiedFailure if
+// the reason is unspecified or unknown.
+func reasonError[R interface {
+	comparable
+	String() string
+}](reason R, kinds map[R]error) error {
+	kind, ok := kinds[reason]
+	if !ok {
+		kind = ErrUnspecifiedFailure
+	}
+	return &Error{Kind: kind, Reason: reason.String()}
+}
@@ -0,0 +1,74 @@
+package network
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/t-0-network/provider-sdk-go/internal/metrics"
+)
+
+// clientMetrics holds the Prometheus collectors of the network client.
+type clientMetrics struct {
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight *prometheus.GaugeVec
+}
+
+// newClientMetrics registers the client collectors, reusing the ones already
+// registered by another client sharing the same registerer.
+func newClientMetrics(registerer prometheus.Registerer) (*clientMetrics, error) {
+	m := &clientMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tzero_network_client_requests_total",
+			Help: "Number of calls made to the T-ZERO Network, by procedure and Connect code.",
+		}, []string{"procedure", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tzero_network_client_request_duration_seconds",
+			Help:    "Latency of calls made to the T-ZERO Network, by procedure.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"procedure"}),
+		requestsInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tzero_network_client_requests_in_flight",
+			Help: "Number of calls to the T-ZERO Network currently in flight, by procedure.",
+		}, []string{"procedure"}),
+	}
+
+	var err error
+	if m.requests, err = metrics.RegisterOrReuse(registerer, m.requests); err != nil {
+		return nil, err
+	}
+	if m.requestDuration, err = metrics.RegisterOrReuse(registerer, m.requestDuration); err != nil {
+		return nil, err
+	}
+	if m.requestsInFlight, err = metrics.RegisterOrReuse(registerer, m.requestsInFlight); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *clientMetrics) interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			procedure := req.Spec().Procedure
+
+			inFlight := m.requestsInFlight.WithLabelValues(procedure)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			startedAt := time.Now()
+			res, err := next(ctx, req)
+
+			code := "ok"
+			if err != nil {
+				code = connect.CodeOf(err).String()
+			}
+			m.requests.WithLabelValues(procedure, code).Inc()
+			m.requestDuration.WithLabelValues(procedure).Observe(time.Since(startedAt).Seconds())
+
+			return res, err
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package network
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
 	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/t-0-network/provider-sdk-go/crypto"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -20,6 +27,11 @@ var (
 	ErrInvalidBaseURL  = errors.New("base URL is not valid")
 	ErrEmptyPrivateKey = errors.New("provider private key is not set")
 	ErrInvalidTimeOut  = errors.New("timeout must be greater than zero")
+	ErrCircuitOpen     = errors.New("circuit breaker is open")
+	ErrRateLimited     = errors.New("client rate limit exceeded")
+	ErrClientClosed    = errors.New("network client is closed")
+
+	ErrIncompatibleTransport = errors.New("transport options are incompatible")
 )
 
 type clientOptions struct {
@@ -27,6 +39,17 @@ type clientOptions struct {
 	signFn         crypto.SignFn
 	timeout        time.Duration
 	connectOptions []connect.ClientOption
+	telemetry      telemetryOptions
+	registerer     prometheus.Registerer
+	tlsConfig      *tls.Config
+	retryPolicy    *RetryPolicy
+	circuitBreaker *CircuitBreakerPolicy
+	rateLimit      *RateLimitPolicy
+
+	baseTransport        http.RoundTripper
+	transportFactory     TransportFactory
+	httpClient           *http.Client
+	transportMiddlewares []TransportMiddleware
 }
 
 func (c *clientOptions) validate() error {
@@ -76,3 +99,61 @@ func WithConnectOptions(options ...connect.ClientOption) ClientOption {
 		c.connectOptions = options
 	}
 }
+
+// WithTracerProvider enables OpenTelemetry tracing of the client. Every call
+// gets a client span, with a child span for request signing, and its W3C
+// trace context is propagated to the network.
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(c *clientOptions) {
+		c.telemetry.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider enables OpenTelemetry metrics of the client.
+func WithMeterProvider(provider metric.MeterProvider) ClientOption {
+	return func(c *clientOptions) {
+		c.telemetry.meterProvider = provider
+	}
+}
+
+// WithPropagator sets the propagator used to inject the trace context into
+// outgoing requests. Defaults to W3C Trace Context.
+func WithPropagator(propagator propagation.TextMapPropagator) ClientOption {
+	return func(c *clientOptions) {
+		c.telemetry.propagator = propagator
+	}
+}
+
+// WithPrometheusRegisterer enables Prometheus metrics of the client: call
+// counts by procedure and Connect code, call latency and in-flight calls.
+// Clients sharing a registerer share the same collectors.
+func WithPrometheusRegisterer(registerer prometheus.Registerer) ClientOption {
+	return func(c *clientOptions) {
+		c.registerer = registerer
+	}
+}
+
+// WithClientCertificate presents the certificate to servers requesting mutual
+// TLS authentication. The request signature is still sent with every call.
+// See WithBaseTransport for custom transports.
+func WithClientCertificate(cert tls.Certificate) ClientOption {
+	return func(c *clientOptions) {
+		c.ensureTLSConfig()
+		c.tlsConfig.Certificates = append(c.tlsConfig.Certificates, cert)
+	}
+}
+
+// WithRootCAs sets the CAs used to verify the server certificate, instead of
+// the system roots. See WithBaseTransport for custom transports.
+func WithRootCAs(pool *x509.CertPool) ClientOption {
+	return func(c *clientOptions) {
+		c.ensureTLSConfig()
+		c.tlsConfig.RootCAs = pool
+	}
+}
+
+func (c *clientOptions) ensureTLSConfig() {
+	if c.tlsConfig == nil {
+		c.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+}
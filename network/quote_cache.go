@@ -0,0 +1,258 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Default values of the QuoteCachePolicy fields
+const (
+	DefaultQuoteCacheMaxTTL     = 2 * time.Second
+	DefaultQuoteCacheMaxEntries = 1000
+)
+
+// QuoteCachePolicy configures the GetQuote caches, see CachePaymentQuotes and
+// CachePaymentIntentQuotes. Zero fields take the default values.
+type QuoteCachePolicy struct {
+	// MaxTTL bounds the time a response is cached, before the expiration of
+	// its quotes.
+	MaxTTL time.Duration
+	// MaxEntries bounds the number of cached responses. Responses are not
+	// cached while the cache is full of unexpired ones.
+	MaxEntries int
+}
+
+func (p QuoteCachePolicy) withDefaults() QuoteCachePolicy {
+	if p.MaxTTL <= 0 {
+		p.MaxTTL = DefaultQuoteCacheMaxTTL
+	}
+	if p.MaxEntries <= 0 {
+		p.MaxEntries = DefaultQuoteCacheMaxEntries
+	}
+	return p
+}
+
+// CachePaymentQuotes returns a client caching the GetQuote responses of the
+// given client, the other procedures are passed through. Concurrent calls
+// with the same request share a single call to the network. A response is
+// cached until the earliest expiration of its quotes, within MaxTTL, so an
+// expired quote is never returned. Only the successful responses are cached:
+// errors and failure results, such as a quote not found, are returned to the
+// callers sharing the call, and the next call goes to the network.
+//
+// Requests are identified by their message, their headers are not taken into
+// account. Every caller gets its own copy of the response.
+//
+// Example:
+//
+//	client, err := network.NewClient(privateKey)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	quotes := network.CachePaymentQuotes(client.Payment, network.QuoteCachePolicy{MaxTTL: time.Second})
+//	res, err := quotes.GetQuote(ctx, connect.NewRequest(quoteRequest))
+func CachePaymentQuotes(client paymentconnect.NetworkServiceClient, policy QuoteCachePolicy) paymentconnect.NetworkServiceClient {
+	return &cachedPaymentClient{
+		NetworkServiceClient: client,
+		cache: newQuoteCache(policy, func(res *payment.GetQuoteResponse) bool {
+			return res.GetSuccess() != nil
+		}, paymentQuoteExpiration, client.GetQuote),
+	}
+}
+
+// CachePaymentIntentQuotes returns a client caching the GetQuote responses of
+// the given client, see CachePaymentQuotes. The indicative quotes of the
+// payment intent service have no expiration, they are cached for MaxTTL.
+func CachePaymentIntentQuotes(
+	client payment_intentconnect.PaymentIntentServiceClient, policy QuoteCachePolicy,
+) payment_intentconnect.PaymentIntentServiceClient {
+	return &cachedPaymentIntentClient{
+		PaymentIntentServiceClient: client,
+		cache: newQuoteCache(policy, func(res *payment_intent.GetQuoteResponse) bool {
+			return res.GetSuccess() != nil
+		}, func(*payment_intent.GetQuoteResponse) (time.Time, bool) {
+			return time.Time{}, false
+		}, client.GetQuote),
+	}
+}
+
+type cachedPaymentClient struct {
+	paymentconnect.NetworkServiceClient
+	cache *quoteCache[payment.GetQuoteRequest, payment.GetQuoteResponse]
+}
+
+func (c *cachedPaymentClient) GetQuote(
+	ctx context.Context, req *connect.Request[payment.GetQuoteRequest],
+) (*connect.Response[payment.GetQuoteResponse], error) {
+	return c.cache.get(ctx, req)
+}
+
+type cachedPaymentIntentClient struct {
+	payment_intentconnect.PaymentIntentServiceClient
+	cache *quoteCache[payment_intent.GetQuoteRequest, payment_intent.GetQuoteResponse]
+}
+
+func (c *cachedPaymentIntentClient) GetQuote(
+	ctx context.Context, req *connect.Request[payment_intent.GetQuoteRequest],
+) (*connect.Response[payment_intent.GetQuoteResponse], error) {
+	return c.cache.get(ctx, req)
+}
+
+// paymentQuoteExpiration returns the earliest expiration of the quotes of
+// the response.
+func paymentQuoteExpiration(res *payment.GetQuoteResponse) (time.Time, bool) {
+	expirations := []*timestamppb.Timestamp{res.GetSuccess().GetExpiration()}
+	for _, quote := range res.GetAllQuotes() {
+		expirations = append(expirations, quote.GetExpiration())
+	}
+
+	var earliest time.Time
+	for _, expiration := range expirations {
+		if expiration == nil {
+			continue
+		}
+		if t := expiration.AsTime(); earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// quoteCache caches the responses of a GetQuote procedure, and collapses the
+// concurrent calls with the same request.
+type quoteCache[Req, Res any] struct {
+	policy     QuoteCachePolicy
+	cacheable  func(*Res) bool
+	expiration func(*Res) (time.Time, bool)
+	fetch      func(context.Context, *connect.Request[Req]) (*connect.Response[Res], error)
+	timeNow    func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]cachedQuote[Res]
+	inFlight map[string]*quoteCall[Res]
+}
+
+type cachedQuote[Res any] struct {
+	response  *connect.Response[Res]
+	expiresAt time.Time
+}
+
+// quoteCall is a call to the network shared by concurrent callers.
+type quoteCall[Res any] struct {
+	done     chan struct{}
+	response *connect.Response[Res]
+	err      error
+}
+
+func newQuoteCache[Req, Res any](
+	policy QuoteCachePolicy,
+	cacheable func(*Res) bool,
+	expiration func(*Res) (time.Time, bool),
+	fetch func(context.Context, *connect.Request[Req]) (*connect.Response[Res], error),
+) *quoteCache[Req, Res] {
+	return &quoteCache[Req, Res]{
+		policy:     policy.withDefaults(),
+		cacheable:  cacheable,
+		expiration: expiration,
+		fetch:      fetch,
+		timeNow:    time.Now,
+		entries:    make(map[string]cachedQuote[Res]),
+		inFlight:   make(map[string]*quoteCall[Res]),
+	}
+}
+
+func (c *quoteCache[Req, Res]) get(ctx context.Context, req *connect.Request[Req]) (*connect.Response[Res], error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(any(req.Msg).(proto.Message))
+	if err != nil {
+		return nil, connect.NewError(connect.CodeInternal, fmt.Errorf("marshaling quote request: %w", err))
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.entries[string(key)]; ok {
+		if c.timeNow().Before(entry.expiresAt) {
+			c.mu.Unlock()
+			return cloneResponse(entry.response), nil
+		}
+		delete(c.entries, string(key))
+	}
+	call, ok := c.inFlight[string(key)]
+	if !ok {
+		call = &quoteCall[Res]{done: make(chan struct{})}
+		c.inFlight[string(key)] = call
+		// The call is shared, it is not cancelled with the context of a caller
+		go c.call(context.WithoutCancel(ctx), string(key), req, call)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+		if call.err != nil {
+			return nil, call.err
+		}
+		return cloneResponse(call.response), nil
+	case <-ctx.Done():
+		return nil, contextError(ctx.Err())
+	}
+}
+
+func (c *quoteCache[Req, Res]) call(ctx context.Context, key string, req *connect.Request[Req], call *quoteCall[Res]) {
+	call.response, call.err = c.fetch(ctx, req)
+	if call.err == nil {
+		// The headers are allocated on first use, not while callers copy them
+		call.response.Header()
+		call.response.Trailer()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inFlight, key)
+	close(call.done)
+	if call.err != nil || !c.cacheable(call.response.Msg) {
+		return
+	}
+
+	now := c.timeNow()
+	expiresAt := now.Add(c.policy.MaxTTL)
+	if expiration, ok := c.expiration(call.response.Msg); ok && expiration.Before(expiresAt) {
+		expiresAt = expiration
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	if len(c.entries) >= c.policy.MaxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.policy.MaxEntries {
+			return
+		}
+	}
+	c.entries[key] = cachedQuote[Res]{response: call.response, expiresAt: expiresAt}
+}
+
+// cloneResponse copies the response, so callers cannot alter the cached one.
+func cloneResponse[Res any](res *connect.Response[Res]) *connect.Response[Res] {
+	msg := any(proto.Clone(any(res.Msg).(proto.Message))).(*Res)
+	clone := connect.NewResponse(msg)
+	for key, values := range res.Header() {
+		clone.Header()[key] = append([]string(nil), values...)
+	}
+	for key, values := range res.Trailer() {
+		clone.Trailer()[key] = append([]string(nil), values...)
+	}
+	return clone
+}
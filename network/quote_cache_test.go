@@ -0,0 +1,209 @@
+package network_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/common"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// quoteNetwork answers GetQuote with quotes expiring after the given
+// validity, once released.
+type quoteNetwork struct {
+	paymentconnect.NetworkServiceClient
+
+	validity time.Duration
+	release  chan struct{}
+	err      error
+	notFound bool
+	calls    atomic.Int32
+}
+
+func (n *quoteNetwork) GetQuote(
+	ctx context.Context, _ *connect.Request[payment.GetQuoteRequest],
+) (*connect.Response[payment.GetQuoteResponse], error) {
+	n.calls.Add(1)
+	if n.release != nil {
+		select {
+		case <-n.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if n.err != nil {
+		return nil, n.err
+	}
+	if n.notFound {
+		return connect.NewResponse(&payment.GetQuoteResponse{
+			Result: &payment.GetQuoteResponse_Failure_{Failure: &payment.GetQuoteResponse_Failure{
+				Reason: payment.GetQuoteResponse_Failure_REASON_QUOTE_NOT_FOUND,
+			}},
+		}), nil
+	}
+
+	expiration := timestamppb.New(time.Now().Add(n.validity))
+	return connect.NewResponse(&payment.GetQuoteResponse{
+		Result: &payment.GetQuoteResponse_Success_{Success: &payment.GetQuoteResponse_Success{
+			Rate:       &common.Decimal{Unscaled: 92, Exponent: -2},
+			Expiration: expiration,
+		}},
+		AllQuotes: []*payment.GetQuoteResponse_ProviderQuote{
+			{Expiration: timestamppb.New(time.Now().Add(2 * n.validity))},
+		},
+	}), nil
+}
+
+func getQuote(ctx context.Context, client paymentconnect.NetworkServiceClient, currency string) (*payment.GetQuoteResponse, error) {
+	res, err := client.GetQuote(ctx, connect.NewRequest(&payment.GetQuoteRequest{
+		PayOutCurrency: currency,
+		QuoteType:      payment.QuoteType_QUOTE_TYPE_REALTIME,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return res.Msg, nil
+}
+
+func TestCachePaymentQuotes_CollapsesConcurrentCalls(t *testing.T) {
+	svc := &quoteNetwork{validity: time.Minute, release: make(chan struct{})}
+	client := network.CachePaymentQuotes(svc, network.QuoteCachePolicy{})
+
+	// The first caller gives up, the others still get the shared response
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := getQuote(ctx, client, "EUR")
+		firstErr <- err
+	}()
+	require.Eventually(t, func() bool { return svc.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.Equal(t, connect.CodeCanceled, connect.CodeOf(<-firstErr))
+
+	var wg sync.WaitGroup
+	responses := make([]*payment.GetQuoteResponse, 10)
+	for i := range responses {
+		wg.Go(func() {
+			res, err := getQuote(context.Background(), client, "EUR")
+			assert.NoError(t, err)
+			responses[i] = res
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(svc.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), svc.calls.Load())
+	require.NotNil(t, responses[0])
+	responses[0].GetSuccess().Rate.Unscaled = 1
+	assert.Equal(t, int64(92), responses[1].GetSuccess().GetRate().GetUnscaled(), "callers must get their own copy")
+
+	// A different request is not served from the cache
+	_, err := getQuote(context.Background(), client, "GBP")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), svc.calls.Load())
+}
+
+func TestCachePaymentQuotes_ExpiresWithEarliestQuote(t *testing.T) {
+	svc := &quoteNetwork{validity: 100 * time.Millisecond}
+	client := network.CachePaymentQuotes(svc, network.QuoteCachePolicy{MaxTTL: time.Hour})
+
+	first, err := getQuote(context.Background(), client, "EUR")
+	require.NoError(t, err)
+	_, err = getQuote(context.Background(), client, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), svc.calls.Load())
+
+	time.Sleep(110 * time.Millisecond)
+	second, err := getQuote(context.Background(), client, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), svc.calls.Load())
+	assert.True(t, second.GetSuccess().GetExpiration().AsTime().After(first.GetSuccess().GetExpiration().AsTime()))
+}
+
+func TestCachePaymentQuotes_BoundedByMaxTTL(t *testing.T) {
+	svc := &quoteNetwork{validity: time.Hour}
+	client := network.CachePaymentQuotes(svc, network.QuoteCachePolicy{MaxTTL: 50 * time.Millisecond})
+
+	for range 2 {
+		_, err := getQuote(context.Background(), client, "EUR")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), svc.calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, err := getQuote(context.Background(), client, "EUR")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), svc.calls.Load())
+}
+
+func TestCachePaymentQuotes_ErrorsNotCached(t *testing.T) {
+	svc := &quoteNetwork{validity: time.Hour, err: connect.NewError(connect.CodeUnavailable, errors.New("unavailable"))}
+	client := network.CachePaymentQuotes(svc, network.QuoteCachePolicy{})
+
+	for range 2 {
+		_, err := getQuote(context.Background(), client, "EUR")
+		assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+	}
+	assert.Equal(t, int32(2), svc.calls.Load())
+}
+
+func TestCachePaymentQuotes_FailuresNotCached(t *testing.T) {
+	svc := &quoteNetwork{validity: time.Hour, notFound: true}
+	client := network.CachePaymentQuotes(svc, network.QuoteCachePolicy{MaxTTL: time.Hour})
+
+	for range 2 {
+		res, err := getQuote(context.Background(), client, "EUR")
+		require.NoError(t, err)
+		assert.NotNil(t, res.GetFailure())
+	}
+	assert.Equal(t, int32(2), svc.calls.Load())
+
+	svc.notFound = false
+	res, err := getQuote(context.Background(), client, "EUR")
+	require.NoError(t, err)
+	assert.NotNil(t, res.GetSuccess())
+}
+
+type indicativeQuoteNetwork struct {
+	payment_intentconnect.PaymentIntentServiceClient
+	calls atomic.Int32
+}
+
+func (n *indicativeQuoteNetwork) GetQuote(
+	context.Context, *connect.Request[payment_intent.GetQuoteRequest],
+) (*connect.Response[payment_intent.GetQuoteResponse], error) {
+	n.calls.Add(1)
+	return connect.NewResponse(&payment_intent.GetQuoteResponse{
+		Result: &payment_intent.GetQuoteResponse_Success_{Success: &payment_intent.GetQuoteResponse_Success{}},
+	}), nil
+}
+
+func TestCachePaymentIntentQuotes(t *testing.T) {
+	svc := &indicativeQuoteNetwork{}
+	client := network.CachePaymentIntentQuotes(svc, network.QuoteCachePolicy{MaxTTL: 50 * time.Millisecond})
+	req := connect.NewRequest(&payment_intent.GetQuoteRequest{Currency: "EUR"})
+
+	for range 2 {
+		_, err := client.GetQuote(context.Background(), req)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), svc.calls.Load())
+
+	time.Sleep(60 * time.Millisecond)
+	_, err := client.GetQuote(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), svc.calls.Load())
+}
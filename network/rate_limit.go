@@ -0,0 +1,272 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/t-0-network/provider-sdk-go/internal/metrics"
+)
+
+// RateLimit configures the token bucket of a procedure. The bucket holds up
+// to Burst tokens and is refilled with Rate tokens per second, every call
+// takes a token.
+type RateLimit struct {
+	// Rate is the number of calls per second. Zero means no limit.
+	Rate float64
+	// Burst is the number of calls which can be made at once. Defaults to
+	// the rate, rounded up, and to at least 1.
+	Burst int
+	// FailFast rejects calls when no token is available, instead of waiting
+	// for one.
+	FailFast bool
+}
+
+// RateLimitPolicy configures the client-side rate limiting, see
+// WithRateLimit.
+type RateLimitPolicy struct {
+	// Default is the limit of the procedures missing from Procedures. Every
+	// procedure has its own bucket.
+	Default RateLimit
+	// Procedures are the limits of specific procedures, by procedure name,
+	// e.g. paymentconnect.NetworkServiceGetQuoteProcedure.
+	Procedures map[string]RateLimit
+}
+
+// RateLimitedError is the cause of the CodeResourceExhausted error of calls
+// rejected by the client-side rate limiting.
+type RateLimitedError struct {
+	// Procedure is the rate limited procedure.
+	Procedure string
+	// RetryAfter is the time until a token is available.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limit of %s exceeded, retry after %s", e.Procedure, e.RetryAfter)
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// WithRateLimit limits the rate of the calls made by the client with token
+// buckets, configured per procedure, to stay within the quotas of the
+// network. Calls wait for a token, within their context, unless the limit is
+// FailFast. A call which cannot get a token in time fails immediately with a
+// CodeResourceExhausted error wrapping a *RateLimitedError.
+//
+// Every attempt of WithRetryPolicy takes a token, and calls rejected by the
+// rate limiting are not retried. Clients created by NewClient share the
+// buckets. With WithPrometheusRegisterer, the tokens left and the time spent
+// waiting for a token are exported by procedure.
+func WithRateLimit(policy RateLimitPolicy) ClientOption {
+	return func(c *clientOptions) {
+		c.rateLimit = &policy
+	}
+}
+
+func (l RateLimit) withDefaults() RateLimit {
+	if l.Burst <= 0 {
+		l.Burst = max(1, int(math.Ceil(l.Rate)))
+	}
+	return l
+}
+
+// rateLimiter holds the token buckets of a client.
+type rateLimiter struct {
+	policy  RateLimitPolicy
+	metrics *rateLimitMetrics
+	timeNow func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(policy RateLimitPolicy, metrics *rateLimitMetrics) *rateLimiter {
+	return &rateLimiter{
+		policy:  policy,
+		metrics: metrics,
+		timeNow: time.Now,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *rateLimiter) interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if err := l.wait(ctx, req.Spec().Procedure); err != nil {
+				return nil, err
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// wait takes a token of the procedure bucket, waiting for it if needed.
+func (l *rateLimiter) wait(ctx context.Context, procedure string) error {
+	bucket := l.bucket(procedure)
+	if bucket == nil {
+		return nil
+	}
+
+	maxWait := time.Duration(math.MaxInt64)
+	if bucket.limit.FailFast {
+		maxWait = 0
+	} else if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(l.timeNow())
+	}
+
+	delay, tokens, ok := bucket.reserve(l.timeNow(), maxWait)
+	l.metrics.observe(procedure, tokens, delay, ok)
+	if !ok {
+		return connect.NewError(connect.CodeResourceExhausted, &RateLimitedError{Procedure: procedure, RetryAfter: delay})
+	}
+	if delay == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// The token was not used
+		bucket.release(l.timeNow())
+		return contextError(ctx.Err())
+	}
+}
+
+// contextError converts the error of a done context to a Connect error.
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	}
+	return connect.NewError(connect.CodeCanceled, err)
+}
+
+// bucket returns the bucket of the procedure, or nil if it is not limited.
+func (l *rateLimiter) bucket(procedure string) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if bucket, ok := l.buckets[procedure]; ok {
+		return bucket
+	}
+
+	limit, ok := l.policy.Procedures[procedure]
+	if !ok {
+		limit = l.policy.Default
+	}
+	var bucket *tokenBucket
+	if limit.Rate > 0 {
+		limit = limit.withDefaults()
+		bucket = &tokenBucket{limit: limit, tokens: float64(limit.Burst), updatedAt: l.timeNow()}
+	}
+	l.buckets[procedure] = bucket
+	return bucket
+}
+
+// tokenBucket lends tokens ahead of time: the tokens go negative while calls
+// wait for them to be refilled.
+type tokenBucket struct {
+	limit RateLimit
+
+	mu        sync.Mutex
+	tokens    float64
+	updatedAt time.Time
+}
+
+// reserve takes a token and returns the time until it is available, and the
+// tokens left. The token is not taken if it is not available within maxWait.
+func (b *tokenBucket) reserve(now time.Time, maxWait time.Duration) (time.Duration, float64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, b.tokens, true
+	}
+
+	delay := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+	if delay > maxWait {
+		return delay, b.tokens, false
+	}
+	b.tokens--
+	return delay, b.tokens, true
+}
+
+// release gives back a reserved token which was not used.
+func (b *tokenBucket) release(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill(now)
+	b.tokens = min(b.tokens+1, float64(b.limit.Burst))
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.updatedAt); elapsed > 0 {
+		b.tokens = min(b.tokens+elapsed.Seconds()*b.limit.Rate, float64(b.limit.Burst))
+		b.updatedAt = now
+	}
+}
+
+// rateLimitMetrics holds the Prometheus collectors of the rate limiting.
+type rateLimitMetrics struct {
+	tokens   *prometheus.GaugeVec
+	wait     *prometheus.HistogramVec
+	rejected *prometheus.CounterVec
+}
+
+// newRateLimitMetrics registers the rate limiting collectors, reusing the
+// ones already registered by another client sharing the same registerer.
+func newRateLimitMetrics(registerer prometheus.Registerer) (*rateLimitMetrics, error) {
+	m := &rateLimitMetrics{
+		tokens: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tzero_network_client_rate_limit_tokens",
+			Help: "Tokens left in the rate limit bucket after the last call, negative while calls wait, by procedure.",
+		}, []string{"procedure"}),
+		wait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tzero_network_client_rate_limit_wait_seconds",
+			Help:    "Time calls to the T-ZERO Network waited for a rate limit token, by procedure.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"procedure"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tzero_network_client_rate_limited_total",
+			Help: "Number of calls to the T-ZERO Network rejected by the client rate limit, by procedure.",
+		}, []string{"procedure"}),
+	}
+
+	var err error
+	if m.tokens, err = metrics.RegisterOrReuse(registerer, m.tokens); err != nil {
+		return nil, err
+	}
+	if m.wait, err = metrics.RegisterOrReuse(registerer, m.wait); err != nil {
+		return nil, err
+	}
+	if m.rejected, err = metrics.RegisterOrReuse(registerer, m.rejected); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (m *rateLimitMetrics) observe(procedure string, tokens float64, delay time.Duration, allowed bool) {
+	if m == nil {
+		return
+	}
+	m.tokens.WithLabelValues(procedure).Set(tokens)
+	if allowed {
+		m.wait.WithLabelValues(procedure).Observe(delay.Seconds())
+	} else {
+		m.rejected.WithLabelValues(procedure).Inc()
+	}
+}
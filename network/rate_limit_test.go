@@ -0,0 +1,109 @@
+package network_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+func TestWithRateLimit_FailFast(t *testing.T) {
+	svc := &flakyNetwork{}
+	registry := prometheus.NewRegistry()
+	client := startFlakyNetwork(t, svc,
+		network.WithPrometheusRegisterer(registry),
+		network.WithRetryPolicy(network.RetryPolicy{InitialBackoff: time.Millisecond}),
+		network.WithRateLimit(network.RateLimitPolicy{
+			Procedures: map[string]network.RateLimit{
+				paymentconnect.NetworkServiceUpdateQuoteProcedure: {Rate: 1, Burst: 2, FailFast: true},
+			},
+		}),
+	)
+
+	require.NoError(t, updateQuote(client))
+	require.NoError(t, updateQuote(client))
+
+	// Rejected without reaching the network, and not retried
+	err := updateQuote(client)
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	assert.ErrorIs(t, err, network.ErrRateLimited)
+	var limitedErr *network.RateLimitedError
+	require.ErrorAs(t, err, &limitedErr)
+	assert.Equal(t, paymentconnect.NetworkServiceUpdateQuoteProcedure, limitedErr.Procedure)
+	assert.InDelta(t, time.Second, limitedErr.RetryAfter, float64(100*time.Millisecond))
+	assert.Len(t, svc.attempts(), 2)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch {
+			case metric.GetCounter() != nil:
+				values[family.GetName()] = metric.GetCounter().GetValue()
+			case metric.GetGauge() != nil:
+				values[family.GetName()] = metric.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, float64(1), values["tzero_network_client_rate_limited_total"])
+	assert.Less(t, values["tzero_network_client_rate_limit_tokens"], float64(1))
+}
+
+func TestWithRateLimit_WaitsForTokens(t *testing.T) {
+	svc := &flakyNetwork{}
+	client := startFlakyNetwork(t, svc, network.WithRateLimit(network.RateLimitPolicy{
+		Default: network.RateLimit{Rate: 20, Burst: 1},
+	}))
+
+	startedAt := time.Now()
+	for range 3 {
+		require.NoError(t, updateQuote(client))
+	}
+	assert.GreaterOrEqual(t, time.Since(startedAt), 90*time.Millisecond)
+	assert.Len(t, svc.attempts(), 3)
+}
+
+func TestWithRateLimit_WaitBoundedByContext(t *testing.T) {
+	svc := &flakyNetwork{}
+	client := startFlakyNetwork(t, svc, network.WithRateLimit(network.RateLimitPolicy{
+		Default: network.RateLimit{Rate: 1},
+	}))
+	require.NoError(t, updateQuote(client))
+
+	// The token is not available before the deadline, the call fails right away
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	startedAt := time.Now()
+	_, err := client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	assert.ErrorIs(t, err, network.ErrRateLimited)
+	assert.Less(t, time.Since(startedAt), 50*time.Millisecond)
+
+	// Waiting for a token is cancelled with the call
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	assert.Equal(t, connect.CodeCanceled, connect.CodeOf(err))
+	assert.Len(t, svc.attempts(), 1)
+}
+
+func TestWithRateLimit_ProcedureOverridesDefault(t *testing.T) {
+	client := startFlakyNetwork(t, &flakyNetwork{}, network.WithRateLimit(network.RateLimitPolicy{
+		Default: network.RateLimit{Rate: 1, FailFast: true},
+		Procedures: map[string]network.RateLimit{
+			// No limit
+			paymentconnect.NetworkServiceUpdateQuoteProcedure: {},
+		},
+	}))
+
+	for range 3 {
+		require.NoError(t, updateQuote(client))
+	}
+}
@@ -0,0 +1,164 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/common"
+)
+
+// Default values of the RetryPolicy fields
+const (
+	DefaultRetryMaxAttempts    = 4
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+	DefaultRetryMultiplier     = 2.0
+	DefaultRetryJitter         = 0.2
+	DefaultRetryMaxElapsedTime = 30 * time.Second
+)
+
+// RetryPolicy configures the retries of failed calls, see WithRetryPolicy.
+// Zero fields take the default values.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, the first one included.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+	// Multiplier is the growth factor of the delay after each retry.
+	Multiplier float64
+	// Jitter randomizes the delays by up to the given fraction, between 0 and 1,
+	// so clients failing together do not retry together.
+	Jitter float64
+	// MaxElapsedTime bounds the time spent on a call, retries included. No
+	// retry is attempted if it would start after that time.
+	MaxElapsedTime time.Duration
+}
+
+// WithRetryPolicy retries calls failing with CodeUnavailable,
+// CodeDeadlineExceeded or CodeResourceExhausted, with an exponential backoff.
+// A Retry-After hint sent by the server is honoured as the minimum delay.
+//
+// Calls rejected by an open circuit of WithCircuitBreaker, or by
+// WithRateLimit, are not retried.
+// Only procedures declared idempotent are retried, which is the case of all
+// the NetworkService and PaymentIntentService procedures. Every attempt is
+// signed again, with a fresh timestamp. The timeout set by WithTimeout
+// applies to every attempt, and the context of the call bounds all of them.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *clientOptions) {
+		policy = policy.withDefaults()
+		c.retryPolicy = &policy
+	}
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+	if p.Jitter <= 0 || p.Jitter > 1 {
+		p.Jitter = DefaultRetryJitter
+	}
+	if p.MaxElapsedTime <= 0 {
+		p.MaxElapsedTime = DefaultRetryMaxElapsedTime
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(retry-1))
+	delay = math.Min(delay, float64(p.MaxBackoff))
+	delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	return time.Duration(delay)
+}
+
+// IsRetryable reports whether err is a Connect error worth retrying. Errors
+// of an open circuit breaker are not, the circuit decides when to try again,
+// and neither are the ones of the client rate limiting.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrRateLimited) {
+		return false
+	}
+	switch connect.CodeOf(err) {
+	case connect.CodeUnavailable, connect.CodeDeadlineExceeded, connect.CodeResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+func retryInterceptor(policy RetryPolicy) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Spec().IdempotencyLevel == connect.IdempotencyUnknown {
+				return next(ctx, req)
+			}
+
+			deadline := time.Now().Add(policy.MaxElapsedTime)
+			if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+				deadline = ctxDeadline
+			}
+
+			for attempt := 1; ; attempt++ {
+				res, err := next(ctx, req)
+				if err == nil || attempt >= policy.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+					return res, err
+				}
+
+				delay := policy.backoff(attempt)
+				if retryAfter, ok := retryAfterHint(err); ok && retryAfter > delay {
+					delay = retryAfter
+				}
+				if time.Now().Add(delay).After(deadline) {
+					return res, err
+				}
+
+				timer := time.NewTimer(delay)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return res, err
+				}
+			}
+		}
+	}
+}
+
+// retryAfterHint returns the delay of the Retry-After header of the error
+// metadata, given in seconds or as an HTTP date.
+func retryAfterHint(err error) (time.Duration, bool) {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return 0, false
+	}
+	value := strings.TrimSpace(connectErr.Meta().Get(common.RetryAfterHeader))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,86 @@
+package network_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+func TestWithRetryPolicy_RetriesAndResigns(t *testing.T) {
+	svc := &flakyNetwork{errs: []error{
+		connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+		connect.NewError(connect.CodeDeadlineExceeded, errors.New("deadline exceeded")),
+	}}
+	client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+		InitialBackoff: 5 * time.Millisecond,
+	}))
+
+	require.NoError(t, updateQuote(client))
+
+	// Every attempt passed the signature verification with its own timestamp
+	timestamps := svc.attempts()
+	require.Len(t, timestamps, 3)
+	assert.NotEqual(t, timestamps[0], timestamps[1])
+	assert.NotEqual(t, timestamps[1], timestamps[2])
+}
+
+func TestWithRetryPolicy_DoesNotRetryOtherCodes(t *testing.T) {
+	svc := &flakyNetwork{errs: []error{
+		connect.NewError(connect.CodeFailedPrecondition, errors.New("failed precondition")),
+	}}
+	client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+		InitialBackoff: time.Millisecond,
+	}))
+
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(updateQuote(client)))
+	assert.Len(t, svc.attempts(), 1)
+}
+
+func TestWithRetryPolicy_StopsAfterMaxAttempts(t *testing.T) {
+	unavailable := connect.NewError(connect.CodeUnavailable, errors.New("unavailable"))
+	svc := &flakyNetwork{errs: []error{unavailable, unavailable, unavailable}}
+	client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+	}))
+
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(updateQuote(client)))
+	assert.Len(t, svc.attempts(), 2)
+}
+
+func TestWithRetryPolicy_HonoursRetryAfter(t *testing.T) {
+	exhausted := func() error {
+		err := connect.NewError(connect.CodeResourceExhausted, errors.New("overloaded"))
+		err.Meta().Set(common.RetryAfterHeader, "1")
+		return err
+	}
+
+	t.Run("waits for the hint", func(t *testing.T) {
+		svc := &flakyNetwork{errs: []error{exhausted()}}
+		client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+			InitialBackoff: time.Millisecond,
+		}))
+
+		startedAt := time.Now()
+		require.NoError(t, updateQuote(client))
+		assert.GreaterOrEqual(t, time.Since(startedAt), time.Second)
+		assert.Len(t, svc.attempts(), 2)
+	})
+
+	t.Run("gives up past the max elapsed time", func(t *testing.T) {
+		svc := &flakyNetwork{errs: []error{exhausted()}}
+		client := startFlakyNetwork(t, svc, network.WithRetryPolicy(network.RetryPolicy{
+			InitialBackoff: time.Millisecond,
+			MaxElapsedTime: 500 * time.Millisecond,
+		}))
+
+		assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(updateQuote(client)))
+		assert.Len(t, svc.attempts(), 1)
+	})
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/t-0-network/provider-sdk-go/common"
 	"github.com/t-0-network/provider-sdk-go/crypto"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func NewSigningTransport(signFn crypto.SignFn, timeNow func() time.Time) *SigningTransport {
@@ -24,7 +25,9 @@ func NewSigningTransport(signFn crypto.SignFn, timeNow func() time.Time) *Signin
 
 // SigningTransport is an HTTP transport that signs requests with a given signing function.
 // It reads the request body, computes its digest, signs it, and adds the signature and public key
-// to the request headers before forwarding the request to the underlying transport.
+// to the request headers before forwarding the request to the underlying transport. Only
+// the body and the timestamp are signed, none of the other headers, such as the
+// idempotency key, are.
 type SigningTransport struct {
 	transport http.RoundTripper
 	sign      crypto.SignFn
@@ -47,13 +50,17 @@ func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	timestampBytes := [8]byte{}
 	binary.LittleEndian.PutUint64(timestampBytes[:], uint64(timestamp))
 
-	// Prepend timestamp bytes to the body and compute the digest
+	// Append the timestamp bytes to the body and compute the digest
 	digest := crypto.LegacyKeccak256(append(body, timestampBytes[:]...))
 
+	span := startSigningSpan(req)
 	signature, pubKeyBytes, err := t.sign(digest)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
 		return nil, fmt.Errorf("signing request body: %w", err)
 	}
+	span.End()
 
 	// Set headers
 	req.Header.Set(common.PublicKeyHeader, "0x"+hex.EncodeToString(pubKeyBytes))
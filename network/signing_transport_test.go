@@ -0,0 +1,69 @@
+package network_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/crypto"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// networkVerifier verifies the requests the way the network does: over the
+// body followed by the timestamp, ignoring any other header.
+func networkVerifier(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+
+		publicKey, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get(common.PublicKeyHeader), "0x"))
+		require.NoError(t, err)
+		signature, err := hex.DecodeString(strings.TrimPrefix(req.Header.Get(common.SignatureHeader), "0x"))
+		require.NoError(t, err)
+		timestamp, err := strconv.ParseInt(req.Header.Get(common.SignatureTimestampHeader), 10, 64)
+		require.NoError(t, err)
+
+		var timestampBytes [8]byte
+		binary.LittleEndian.PutUint64(timestampBytes[:], uint64(timestamp))
+		signerKey, err := crypto.GetPublicKeyFromBytes(publicKey)
+		require.NoError(t, err)
+
+		digest := crypto.LegacyKeccak256(append(bytes.Clone(body), timestampBytes[:]...))
+		if !crypto.VerifySignature(signerKey, digest, signature[:64]) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/proto")
+	})
+}
+
+func TestSigningTransport_NetworkVerifierInterop(t *testing.T) {
+	privateKey, _ := newTestKey(t)
+
+	server := httptest.NewServer(networkVerifier(t))
+	t.Cleanup(server.Close)
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewNetworkServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+
+	// The idempotency key is not part of the signed payload
+	ctx := network.WithIdempotencyKey(context.Background(), "payment-client-id")
+	_, err = client.UpdateQuote(ctx, connect.NewRequest(&payment.UpdateQuoteRequest{}))
+	assert.NoError(t, err)
+}
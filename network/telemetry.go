@@ -0,0 +1,117 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/t-0-network/provider-sdk-go/network"
+
+// correlationIDAttribute is the span attribute of the correlation ID of a call.
+const correlationIDAttribute = attribute.Key("tzero.correlation_id")
+
+type telemetryOptions struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// clientTelemetry instruments network client calls with OpenTelemetry spans
+// and metrics.
+type clientTelemetry struct {
+	tracer       trace.Tracer
+	propagator   propagation.TextMapPropagator
+	callDuration metric.Float64Histogram
+}
+
+// newClientTelemetry returns nil if neither a tracer provider nor a meter
+// provider is configured.
+func newClientTelemetry(opts telemetryOptions) *clientTelemetry {
+	if opts.tracerProvider == nil && opts.meterProvider == nil {
+		return nil
+	}
+
+	tracerProvider := opts.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = tracenoop.NewTracerProvider()
+	}
+	meterProvider := opts.meterProvider
+	if meterProvider == nil {
+		meterProvider = metricnoop.NewMeterProvider()
+	}
+	propagator := opts.propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	callDuration, err := meterProvider.Meter(instrumentationName).Float64Histogram("tzero.network.client.duration",
+		metric.WithDescription("Duration of calls made to the T-ZERO Network."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return &clientTelemetry{
+		tracer:       tracerProvider.Tracer(instrumentationName),
+		propagator:   propagator,
+		callDuration: callDuration,
+	}
+}
+
+// interceptor starts a client span for every call and injects its W3C trace
+// context into the request headers, next to the signature headers.
+func (t *clientTelemetry) interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			procedure := req.Spec().Procedure
+			attrs := telemetry.ProcedureAttributes(procedure)
+
+			ctx, span := t.tracer.Start(ctx, strings.TrimPrefix(procedure, "/"),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attrs...),
+			)
+			defer span.End()
+			// Not a metric attribute, every call has its own
+			span.SetAttributes(correlationIDAttribute.String(req.Header().Get(common.CorrelationIDHeader)))
+
+			t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header()))
+
+			startedAt := time.Now()
+			res, err := next(ctx, req)
+
+			if err != nil {
+				code := semconv.RPCConnectRPCErrorCodeKey.String(connect.CodeOf(err).String())
+				attrs = append(attrs, code)
+				span.SetAttributes(code)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			t.callDuration.Record(ctx, time.Since(startedAt).Seconds(), metric.WithAttributes(attrs...))
+
+			return res, err
+		}
+	}
+}
+
+// startSigningSpan starts a child span of the span found in the request
+// context, if any. Without a parent span the returned span is a no-op.
+func startSigningSpan(req *http.Request) trace.Span {
+	tracer := trace.SpanFromContext(req.Context()).TracerProvider().Tracer(instrumentationName)
+	_, span := tracer.Start(req.Context(), "SignRequest", trace.WithSpanKind(trace.SpanKindInternal))
+	return span
+}
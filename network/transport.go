@@ -0,0 +1,135 @@
+package network
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TransportMiddleware wraps the transport of the network client, e.g. to log
+// or to record the requests. Requests are not signed yet when they reach a
+// middleware, the signature is added right before the base transport.
+type TransportMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// WithBaseTransport sets the transport sending the signed requests, e.g. an
+// *http.Transport with a proxy, a custom dialer or connection pool settings.
+// It defaults to http.DefaultTransport.
+//
+// The TLS options, WithClientCertificate and WithRootCAs, are applied to a
+// clone of the transport, which must then be an *http.Transport. It cannot
+// be combined with WithTransportFactory.
+func WithBaseTransport(transport http.RoundTripper) ClientOption {
+	return func(c *clientOptions) {
+		c.baseTransport = transport
+	}
+}
+
+// TransportFactory creates the base transport of a client, with the TLS
+// config of the TLS options, nil without TLS options.
+type TransportFactory func(tlsConfig *tls.Config) http.RoundTripper
+
+// WithTransportFactory creates the base transport of the client, which is
+// closed by Client.Close if it implements io.Closer, e.g. the HTTP/3
+// transport of the http3 package. It cannot be combined with
+// WithBaseTransport or the transport of WithHTTPClient.
+func WithTransportFactory(factory TransportFactory) ClientOption {
+	return func(c *clientOptions) {
+		c.transportFactory = factory
+	}
+}
+
+// WithHTTPClient uses a copy of the given client, keeping its settings such as
+// its timeout, cookie jar or redirect policy, with its transport wrapped by the
+// request signing and the middlewares. Its transport is the base transport,
+// unless WithBaseTransport is set. If its timeout is 0, the timeout of
+// WithTimeout is used.
+func WithHTTPClient(client *http.Client) ClientOption {
+	return func(c *clientOptions) {
+		c.httpClient = client
+	}
+}
+
+// WithTransportMiddlewares appends middlewares to the transport of the
+// client. The first middleware is the outermost, the request signing always
+// comes after the last one.
+func WithTransportMiddlewares(middlewares ...TransportMiddleware) ClientOption {
+	return func(c *clientOptions) {
+		c.transportMiddlewares = append(c.transportMiddlewares, middlewares...)
+	}
+}
+
+// newHTTPClient builds the client: middlewares, then signing, then the base
+// transport. The base transport is also returned if it was created for the
+// client, rather than given or shared.
+func newHTTPClient(options *clientOptions) (client *http.Client, ownTransport http.RoundTripper, err error) {
+	base, owned, err := options.base()
+	if err != nil {
+		return nil, nil, err
+	}
+	if owned {
+		ownTransport = base
+	}
+
+	signing := NewSigningTransport(options.signFn, time.Now)
+	signing.transport = base
+
+	var transport http.RoundTripper = signing
+	for i := len(options.transportMiddlewares) - 1; i >= 0; i-- {
+		transport = options.transportMiddlewares[i](transport)
+	}
+
+	client = &http.Client{Timeout: options.timeout}
+	if options.httpClient != nil {
+		*client = *options.httpClient
+		if client.Timeout == 0 {
+			client.Timeout = options.timeout
+		}
+	}
+	client.Transport = transport
+	return client, ownTransport, nil
+}
+
+// base returns the base transport, with the TLS options applied, and whether
+// it was created for the client.
+func (c *clientOptions) base() (http.RoundTripper, bool, error) {
+	base := c.baseTransport
+	if base == nil && c.httpClient != nil {
+		base = c.httpClient.Transport
+	}
+
+	if c.transportFactory != nil {
+		if base != nil {
+			return nil, false, fmt.Errorf("%w: the transport factory replaces the base transport", ErrIncompatibleTransport)
+		}
+		return c.transportFactory(c.tlsConfig), true, nil
+	}
+
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if c.tlsConfig == nil {
+		return base, false, nil
+	}
+
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: TLS options require an *http.Transport, got %T", ErrIncompatibleTransport, base)
+	}
+	transport = transport.Clone()
+	transport.TLSClientConfig = mergeTLSConfig(transport.TLSClientConfig, c.tlsConfig)
+	return transport, true, nil
+}
+
+// mergeTLSConfig applies the TLS options to the TLS config of a transport.
+func mergeTLSConfig(base, options *tls.Config) *tls.Config {
+	if base == nil {
+		return options
+	}
+	merged := base.Clone()
+	merged.Certificates = append(merged.Certificates, options.Certificates...)
+	if options.RootCAs != nil {
+		merged.RootCAs = options.RootCAs
+	}
+	return merged
+}
@@ -0,0 +1,128 @@
+package network_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// roundTripLog records the transports a request went through, and whether it
+// was signed at that point.
+type roundTripLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *roundTripLog) transport(name string, next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		entry := name
+		if req.Header.Get(common.SignatureHeader) != "" {
+			entry += " (signed)"
+		}
+		l.mu.Lock()
+		l.entries = append(l.entries, entry)
+		l.mu.Unlock()
+		return next.RoundTrip(req)
+	})
+}
+
+func (l *roundTripLog) middleware(name string) network.TransportMiddleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return l.transport(name, next)
+	}
+}
+
+func (l *roundTripLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries
+}
+
+func TestTransportMiddlewares_SigningIsLast(t *testing.T) {
+	log := &roundTripLog{}
+	client := startFlakyNetwork(t, &flakyNetwork{},
+		network.WithTransportMiddlewares(log.middleware("first")),
+		network.WithTransportMiddlewares(log.middleware("second")),
+		network.WithBaseTransport(log.transport("base", http.DefaultTransport)),
+	)
+
+	require.NoError(t, updateQuote(client))
+	assert.Equal(t, []string{"first", "second", "base (signed)"}, log.get())
+}
+
+func TestWithHTTPClient_WrapsClientTransport(t *testing.T) {
+	log := &roundTripLog{}
+	httpClient := &http.Client{Transport: log.transport("client", http.DefaultTransport)}
+
+	client := startFlakyNetwork(t, &flakyNetwork{},
+		network.WithHTTPClient(httpClient),
+		network.WithTransportMiddlewares(log.middleware("middleware")),
+	)
+
+	require.NoError(t, updateQuote(client))
+	assert.Equal(t, []string{"middleware", "client (signed)"}, log.get())
+	assert.Zero(t, httpClient.Timeout, "the given client must not be modified")
+}
+
+func TestWithBaseTransport_IncompatibleOptions(t *testing.T) {
+	privateKey, _ := newTestKey(t)
+	custom := roundTripFunc(http.DefaultTransport.RoundTrip)
+
+	tests := map[string][]network.ClientOption{
+		"TLS options need an *http.Transport": {
+			network.WithBaseTransport(custom),
+			network.WithRootCAs(x509.NewCertPool()),
+		},
+		"the transport factory replaces the base transport": {
+			network.WithHTTPClient(&http.Client{Transport: http.DefaultTransport}),
+			network.WithTransportFactory(func(*tls.Config) http.RoundTripper { return custom }),
+		},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := network.NewServiceClient(privateKey, paymentconnect.NewNetworkServiceClient, opts...)
+			assert.ErrorIs(t, err, network.ErrIncompatibleTransport)
+		})
+	}
+}
+
+func TestWithBaseTransport_AppliesTLSOptionsToClone(t *testing.T) {
+	privateKey, publicKey := newTestKey(t)
+	handler := newNetworkHandler(t, publicKey, (&flakyNetwork{}).service())
+	certs := make(chan []*x509.Certificate, 1)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		certs <- r.TLS.PeerCertificates
+		handler.ServeHTTP(w, r)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	base := &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}}
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewNetworkServiceClient,
+		network.WithBaseURL(srv.URL),
+		network.WithBaseTransport(base),
+		network.WithRootCAs(roots),
+		network.WithClientCertificate(srv.TLS.Certificates[0]),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, updateQuote(client))
+	assert.NotEmpty(t, <-certs)
+
+	// The given transport is not modified
+	assert.Empty(t, base.TLSClientConfig.Certificates)
+	assert.Nil(t, base.TLSClientConfig.RootCAs)
+}
@@ -0,0 +1,229 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	entryFileExt   = ".json"
+	corruptFileExt = ".corrupt"
+)
+
+// FileStore is a Store keeping each entry in a JSON file of a directory.
+// Entries are written to a temporary file, synced and then moved in place, so
+// a crash never leaves a partially written entry behind.
+//
+// Entries which cannot be decoded, e.g. edited by hand, are moved aside to a
+// file with the .corrupt extension, and reported to the handler of
+// WithCorruptEntryHandler, so they do not block the delivery of the others.
+type FileStore struct {
+	dir       string
+	onCorrupt func(path string, err error)
+	mu        sync.Mutex
+}
+
+// FileStoreOption configures a FileStore.
+type FileStoreOption func(*FileStore)
+
+// WithCorruptEntryHandler sets the handler called with the path of the
+// entries moved aside because they cannot be decoded, and the decoding error.
+// Defaults to logging them using the default slog logger.
+func WithCorruptEntryHandler(handler func(path string, err error)) FileStoreOption {
+	return func(s *FileStore) {
+		s.onCorrupt = handler
+	}
+}
+
+// NewFileStore creates a FileStore in the given directory, which is created
+// if it does not exist.
+func NewFileStore(dir string, opts ...FileStoreOption) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating outbox directory: %w", err)
+	}
+
+	store := &FileStore{dir: dir, onCorrupt: logCorruptEntry}
+	for _, opt := range opts {
+		opt(store)
+	}
+	return store, nil
+}
+
+// logCorruptEntry logs the entry using the default slog logger.
+func logCorruptEntry(path string, err error) {
+	slog.Error("corrupt outbox entry moved aside",
+		slog.String("path", path),
+		slog.Any("error", err),
+	)
+}
+
+func (s *FileStore) Add(_ context.Context, entry Entry) error {
+	path, err := s.path(entry.Key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmp, err := s.writeTemp(entry)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// Unlike a rename, a link fails if the entry already exists
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return ErrDuplicateEntry
+		}
+		return fmt.Errorf("storing outbox entry: %w", err)
+	}
+	return s.syncDir()
+}
+
+func (s *FileStore) Update(_ context.Context, entry Entry) error {
+	path, err := s.path(entry.Key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrEntryNotFound
+		}
+		return fmt.Errorf("reading outbox entry: %w", err)
+	}
+
+	tmp, err := s.writeTemp(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("storing outbox entry: %w", err)
+	}
+	return s.syncDir()
+}
+
+func (s *FileStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("deleting outbox entry: %w", err)
+	}
+	return s.syncDir()
+}
+
+func (s *FileStore) List(context.Context) ([]Entry, error) {
+	entries, corrupt, err := s.list()
+	// Reported without the lock, the handler may use the store
+	for path, err := range corrupt {
+		s.onCorrupt(path, err)
+	}
+	return entries, err
+}
+
+// list returns the stored entries, and the errors of the corrupt entries
+// moved aside by their new path.
+func (s *FileStore) list() ([]Entry, map[string]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing outbox entries: %w", err)
+	}
+
+	var entries []Entry
+	corrupt := make(map[string]error)
+	for _, file := range files {
+		// Temporary files start with a dot
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || filepath.Ext(file.Name()) != entryFileExt {
+			continue
+		}
+
+		path := filepath.Join(s.dir, file.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, corrupt, fmt.Errorf("reading outbox entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			corruptPath := strings.TrimSuffix(path, entryFileExt) + corruptFileExt
+			if err := os.Rename(path, corruptPath); err != nil {
+				return nil, corrupt, fmt.Errorf("moving aside corrupt outbox entry: %w", err)
+			}
+			corrupt[corruptPath] = fmt.Errorf("decoding outbox entry %s: %w", file.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, corrupt, nil
+}
+
+// path returns the file of the entry with the given key.
+func (s *FileStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, ".") || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEntryKey, key)
+	}
+	return filepath.Join(s.dir, key+entryFileExt), nil
+}
+
+// writeTemp writes the entry to a synced temporary file of the directory.
+func (s *FileStore) writeTemp(entry Entry) (string, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("encoding outbox entry: %w", err)
+	}
+
+	file, err := os.CreateTemp(s.dir, ".entry-*")
+	if err != nil {
+		return "", fmt.Errorf("creating outbox entry: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing outbox entry: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// syncDir makes the changes of the directory entries durable.
+func (s *FileStore) syncDir() error {
+	dir, err := os.Open(s.dir)
+	if err != nil {
+		return fmt.Errorf("syncing outbox directory: %w", err)
+	}
+	defer dir.Close()
+
+	// Directories cannot be synced on every platform, e.g. Windows
+	if err := dir.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) && !errors.Is(err, fs.ErrPermission) {
+		return fmt.Errorf("syncing outbox directory: %w", err)
+	}
+	return nil
+}
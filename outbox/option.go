@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Default values of the outbox options
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultMaxRetryBackoff = 5 * time.Minute
+	DefaultPollInterval    = time.Minute
+)
+
+var (
+	ErrDuplicateEntry  = errors.New("outbox entry already exists")
+	ErrEntryNotFound   = errors.New("outbox entry not found")
+	ErrInvalidEntryKey = errors.New("invalid outbox entry key")
+	ErrInvalidRequest  = errors.New("invalid outbox request")
+	ErrNoClient        = errors.New("no network client to deliver the entry")
+	ErrUnknownKind     = errors.New("unknown outbox entry kind")
+	ErrOutboxStopped   = errors.New("outbox is stopped")
+)
+
+// Option configures an Outbox.
+type Option func(*options)
+
+type options struct {
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+	maxAttempts     int
+	pollInterval    time.Duration
+	deliveredHook   func(ctx context.Context, entry Entry, response proto.Message)
+	failedHook      func(ctx context.Context, entry Entry, err error)
+}
+
+func defaultOptions() *options {
+	return &options{
+		retryBackoff:    DefaultRetryBackoff,
+		maxRetryBackoff: DefaultMaxRetryBackoff,
+		pollInterval:    DefaultPollInterval,
+		failedHook:      defaultFailedHook,
+	}
+}
+
+// defaultFailedHook logs the entry using the default slog logger.
+func defaultFailedHook(ctx context.Context, entry Entry, err error) {
+	slog.ErrorContext(ctx, "outbox entry could not be delivered",
+		slog.String("key", entry.Key),
+		slog.Int("attempts", entry.Attempts),
+		slog.Any("error", err),
+	)
+}
+
+// WithRetryBackoff sets the delay before the first retry of a failed
+// delivery, and the maximum delay. The delay doubles after each failure.
+// Defaults to DefaultRetryBackoff and DefaultMaxRetryBackoff.
+func WithRetryBackoff(backoff, maxBackoff time.Duration) Option {
+	return func(o *options) {
+		if backoff > 0 {
+			o.retryBackoff = backoff
+		}
+		if maxBackoff > 0 {
+			o.maxRetryBackoff = maxBackoff
+		}
+	}
+}
+
+// WithMaxAttempts sets the number of deliveries after which an entry is given
+// up and passed to the failed hook. Defaults to 0, retrying until delivered.
+func WithMaxAttempts(attempts int) Option {
+	return func(o *options) {
+		o.maxAttempts = attempts
+	}
+}
+
+// WithPollInterval sets how often the store is checked for entries added by
+// other processes. Defaults to DefaultPollInterval.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// WithDeliveredHook registers a hook called with the response of every
+// delivered entry, e.g. to handle a ConfirmFundsReceivedResponse rejecting the
+// funds. The response is nil if the network rejected the call with
+// CodeAlreadyExists, the entry being delivered already.
+func WithDeliveredHook(hook func(ctx context.Context, entry Entry, response proto.Message)) Option {
+	return func(o *options) {
+		o.deliveredHook = hook
+	}
+}
+
+// WithFailedHook registers a hook called with the entries which are given up:
+// rejected by the network, or out of attempts. They are removed from the
+// store afterwards. Defaults to logging with the default slog logger.
+func WithFailedHook(hook func(ctx context.Context, entry Entry, err error)) Option {
+	return func(o *options) {
+		o.failedHook = hook
+	}
+}
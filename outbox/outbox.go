@@ -0,0 +1,352 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"google.golang.org/protobuf/proto"
+)
+
+// Outbox delivers outbound network calls reliably. Calls are persisted in a
+// Store when they are enqueued, and removed once the network accepted them.
+// Failed deliveries are retried with an exponential backoff, and the entries
+// left in the store, e.g. by a crash, are delivered again on Start.
+//
+// Entries are deduplicated by payment ID, or payment intent ID: enqueuing a
+// call for a payment whose call is pending is a no-op.
+type Outbox struct {
+	store          Store
+	payments       paymentconnect.NetworkServiceClient
+	paymentIntents payment_intentconnect.PaymentIntentServiceClient
+	opts           *options
+
+	wake chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// New creates an outbox delivering its entries with the given clients. A
+// client can be nil if the calls it delivers are not used, e.g. pay-out
+// providers do not need the PaymentIntentService client.
+//
+// Example:
+//
+//	store, err := outbox.NewFileStore("/var/lib/provider/outbox")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	box := outbox.New(store, client.Payment, client.PaymentIntent)
+//	if err := box.Start(ctx); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer box.Stop(context.Background())
+//
+//	err = box.FinalizePayout(ctx, &payment.FinalizePayoutRequest{PaymentId: paymentID, ...})
+func New(
+	store Store,
+	payments paymentconnect.NetworkServiceClient,
+	paymentIntents payment_intentconnect.PaymentIntentServiceClient,
+	opts ...Option,
+) *Outbox {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &Outbox{
+		store:          store,
+		payments:       payments,
+		paymentIntents: paymentIntents,
+		opts:           options,
+		wake:           make(chan struct{}, 1),
+		done:           make(chan struct{}),
+	}
+}
+
+// FinalizePayout enqueues a FinalizePayout call, deduplicated by payment ID.
+func (o *Outbox) FinalizePayout(ctx context.Context, req *payment.FinalizePayoutRequest) error {
+	if req.GetPaymentId() == 0 {
+		return fmt.Errorf("%w: payment ID is not set", ErrInvalidRequest)
+	}
+	return o.enqueue(ctx, KindFinalizePayout, req.GetPaymentId(), req)
+}
+
+// ConfirmFundsReceived enqueues a ConfirmFundsReceived call, deduplicated by
+// payment intent ID. The response is passed to the hook of WithDeliveredHook.
+func (o *Outbox) ConfirmFundsReceived(ctx context.Context, req *payment_intent.ConfirmFundsReceivedRequest) error {
+	if req.GetPaymentIntentId() == 0 {
+		return fmt.Errorf("%w: payment intent ID is not set", ErrInvalidRequest)
+	}
+	return o.enqueue(ctx, KindConfirmFundsReceived, req.GetPaymentIntentId(), req)
+}
+
+func (o *Outbox) enqueue(ctx context.Context, kind Kind, id uint64, req proto.Message) error {
+	if !o.hasClient(kind) {
+		return fmt.Errorf("%w: %s", ErrNoClient, kind)
+	}
+
+	request, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+	}
+
+	now := time.Now()
+	err = o.store.Add(ctx, Entry{
+		Key:           string(kind) + "-" + strconv.FormatUint(id, 10),
+		Kind:          kind,
+		Request:       request,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	})
+	if err != nil && !errors.Is(err, ErrDuplicateEntry) {
+		return err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// hasClient tells whether the outbox has the client delivering the entries
+// of the given kind.
+func (o *Outbox) hasClient(kind Kind) bool {
+	switch kind {
+	case KindFinalizePayout:
+		return o.payments != nil
+	case KindConfirmFundsReceived:
+		return o.paymentIntents != nil
+	default:
+		return false
+	}
+}
+
+// Start replays the entries of the store, and delivers the entries in the
+// background until Stop is called. It fails if the store cannot be read: the
+// entries it lists are the first ones delivered.
+// Calling it again has no effect, and it fails with ErrOutboxStopped once
+// the outbox is stopped.
+func (o *Outbox) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.stopped {
+		return ErrOutboxStopped
+	}
+	if o.started {
+		return nil
+	}
+	entries, err := o.store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("replaying outbox: %w", err)
+	}
+	o.started = true
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	o.cancel = cancel
+	go o.run(runCtx, entries)
+	return nil
+}
+
+// Stop stops the deliveries and waits for an in-flight one, within the given
+// context. Pending entries are kept in the store, to be delivered on the
+// next Start. It can be used as a provider.ShutdownHook.
+func (o *Outbox) Stop(ctx context.Context) error {
+	o.mu.Lock()
+	o.stopped = true
+	started := o.started
+	if started {
+		o.cancel()
+	}
+	o.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	select {
+	case <-o.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run delivers the replayed entries, then the entries of the store whenever
+// one is due or enqueued.
+func (o *Outbox) run(ctx context.Context, replayed []Entry) {
+	defer close(o.done)
+
+	timer := time.NewTimer(time.Until(o.deliverEntries(ctx, replayed)))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+
+		next := o.deliverDue(ctx)
+		timer.Reset(time.Until(next))
+	}
+}
+
+// deliverDue delivers the entries of the store which are due, and returns
+// when the next delivery is due.
+func (o *Outbox) deliverDue(ctx context.Context) time.Time {
+	entries, err := o.store.List(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			logStoreError(ctx, "listing outbox entries", "", err)
+		}
+		return time.Now().Add(o.opts.retryBackoff)
+	}
+	return o.deliverEntries(ctx, entries)
+}
+
+// deliverEntries delivers the given entries which are due, and returns when
+// the next delivery is due.
+func (o *Outbox) deliverEntries(ctx context.Context, entries []Entry) time.Time {
+	next := time.Now().Add(o.opts.pollInterval)
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return next
+		}
+		if entry.NextAttemptAt.After(time.Now()) {
+			if entry.NextAttemptAt.Before(next) {
+				next = entry.NextAttemptAt
+			}
+			continue
+		}
+
+		retryAt, retry := o.deliverEntry(ctx, entry)
+		if retry && retryAt.Before(next) {
+			next = retryAt
+		}
+	}
+	return next
+}
+
+// deliverEntry delivers the entry and updates the store, and returns when
+// the entry must be retried, if it must.
+func (o *Outbox) deliverEntry(ctx context.Context, entry Entry) (time.Time, bool) {
+	response, err := o.deliver(ctx, entry)
+	if ctx.Err() != nil {
+		return time.Time{}, false
+	}
+
+	// The network already has the call, e.g. delivered before a crash
+	if err == nil || connect.CodeOf(err) == connect.CodeAlreadyExists {
+		if err := o.store.Delete(ctx, entry.Key); err != nil {
+			// Delivered again later, which the network procedures allow
+			logStoreError(ctx, "deleting delivered outbox entry", entry.Key, err)
+		}
+		if o.opts.deliveredHook != nil {
+			o.opts.deliveredHook(ctx, entry, response)
+		}
+		return time.Time{}, false
+	}
+
+	entry.Attempts++
+	entry.LastError = err.Error()
+	if isPermanent(err) || (o.opts.maxAttempts > 0 && entry.Attempts >= o.opts.maxAttempts) {
+		o.opts.failedHook(ctx, entry, err)
+		if err := o.store.Delete(ctx, entry.Key); err != nil {
+			logStoreError(ctx, "deleting failed outbox entry", entry.Key, err)
+		}
+		return time.Time{}, false
+	}
+
+	entry.NextAttemptAt = time.Now().Add(o.backoff(entry.Attempts))
+	if err := o.store.Update(ctx, entry); err != nil {
+		logStoreError(ctx, "updating outbox entry", entry.Key, err)
+	}
+	return entry.NextAttemptAt, true
+}
+
+// logStoreError logs a store error using the default slog logger. The
+// entries stay in the store, so they are delivered later.
+func logStoreError(ctx context.Context, msg, key string, err error) {
+	slog.WarnContext(ctx, msg, slog.String("key", key), slog.Any("error", err))
+}
+
+// backoff returns the delay before the next delivery, after the given number
+// of failed ones.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	backoff := o.opts.retryBackoff
+	for i := 1; i < attempts && backoff < o.opts.maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, o.opts.maxRetryBackoff)
+}
+
+// deliver calls the network procedure of the entry.
+func (o *Outbox) deliver(ctx context.Context, entry Entry) (proto.Message, error) {
+	switch entry.Kind {
+	case KindFinalizePayout:
+		if o.payments == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNoClient, entry.Kind)
+		}
+		req := &payment.FinalizePayoutRequest{}
+		if err := proto.Unmarshal(entry.Request, req); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+		}
+		res, err := o.payments.FinalizePayout(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return res.Msg, nil
+
+	case KindConfirmFundsReceived:
+		if o.paymentIntents == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNoClient, entry.Kind)
+		}
+		req := &payment_intent.ConfirmFundsReceivedRequest{}
+		if err := proto.Unmarshal(entry.Request, req); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidRequest, err)
+		}
+		res, err := o.paymentIntents.ConfirmFundsReceived(ctx, connect.NewRequest(req))
+		if err != nil {
+			return nil, err
+		}
+		return res.Msg, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKind, entry.Kind)
+	}
+}
+
+// isPermanent tells whether a delivery error cannot be fixed by retrying.
+func isPermanent(err error) bool {
+	if errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrUnknownKind) {
+		return true
+	}
+
+	switch connect.CodeOf(err) {
+	case connect.CodeInvalidArgument,
+		connect.CodeNotFound,
+		connect.CodeFailedPrecondition,
+		connect.CodeOutOfRange,
+		connect.CodeUnimplemented:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,268 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"google.golang.org/protobuf/proto"
+)
+
+// network records the delivered calls, failing with the queued errors first.
+type network struct {
+	mu        sync.Mutex
+	errs      []error
+	delivered []proto.Message
+}
+
+func (n *network) call(req proto.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.errs) > 0 {
+		err := n.errs[0]
+		n.errs = n.errs[1:]
+		return err
+	}
+	n.delivered = append(n.delivered, req)
+	return nil
+}
+
+func (n *network) calls() []proto.Message {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]proto.Message(nil), n.delivered...)
+}
+
+// payments returns the client of the network payment service.
+func (n *network) payments() paymentNetwork {
+	return paymentNetwork{network: n}
+}
+
+// paymentIntents returns the client of the network payment intent service.
+func (n *network) paymentIntents() paymentIntentNetwork {
+	return paymentIntentNetwork{network: n}
+}
+
+type paymentNetwork struct {
+	paymentconnect.NetworkServiceClient
+	network *network
+}
+
+type paymentIntentNetwork struct {
+	payment_intentconnect.PaymentIntentServiceClient
+	network *network
+}
+
+func (n paymentNetwork) FinalizePayout(
+	_ context.Context, req *connect.Request[payment.FinalizePayoutRequest],
+) (*connect.Response[payment.FinalizePayoutResponse], error) {
+	if err := n.network.call(req.Msg); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&payment.FinalizePayoutResponse{}), nil
+}
+
+func (n paymentIntentNetwork) ConfirmFundsReceived(
+	_ context.Context, req *connect.Request[payment_intent.ConfirmFundsReceivedRequest],
+) (*connect.Response[payment_intent.ConfirmFundsReceivedResponse], error) {
+	if err := n.network.call(req.Msg); err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(&payment_intent.ConfirmFundsReceivedResponse{
+		Result: &payment_intent.ConfirmFundsReceivedResponse_Accept_{
+			Accept: &payment_intent.ConfirmFundsReceivedResponse_Accept{},
+		},
+	}), nil
+}
+
+func finalizePayout(paymentID uint64) *payment.FinalizePayoutRequest {
+	return &payment.FinalizePayoutRequest{
+		PaymentId: paymentID,
+		Result:    &payment.FinalizePayoutRequest_Success_{Success: &payment.FinalizePayoutRequest_Success{}},
+	}
+}
+
+func startOutbox(t *testing.T, box *Outbox) {
+	t.Helper()
+	require.NoError(t, box.Start(context.Background()))
+	t.Cleanup(func() {
+		assert.NoError(t, box.Stop(context.Background()))
+	})
+}
+
+func waitForEmptyStore(t *testing.T, store Store) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		entries, err := store.List(context.Background())
+		return err == nil && len(entries) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestOutbox_DeliversDeduplicatedCalls(t *testing.T) {
+	net := &network{}
+	store := NewMemoryStore()
+
+	var mu sync.Mutex
+	var responses []proto.Message
+	box := New(store, net.payments(), net.paymentIntents(), WithDeliveredHook(func(_ context.Context, _ Entry, response proto.Message) {
+		mu.Lock()
+		defer mu.Unlock()
+		responses = append(responses, response)
+	}))
+
+	ctx := context.Background()
+	require.NoError(t, box.FinalizePayout(ctx, finalizePayout(1)))
+	require.NoError(t, box.FinalizePayout(ctx, finalizePayout(1)))
+	require.NoError(t, box.ConfirmFundsReceived(ctx, &payment_intent.ConfirmFundsReceivedRequest{
+		PaymentIntentId:      1,
+		TransactionReference: "ref",
+	}))
+
+	entries, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	startOutbox(t, box)
+	waitForEmptyStore(t, store)
+	require.NoError(t, box.Stop(ctx))
+
+	calls := net.calls()
+	require.Len(t, calls, 2)
+	assert.True(t, proto.Equal(finalizePayout(1), calls[0]))
+	assert.Equal(t, "ref", calls[1].(*payment_intent.ConfirmFundsReceivedRequest).TransactionReference)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, responses, 2)
+	assert.NotNil(t, responses[1].(*payment_intent.ConfirmFundsReceivedResponse).GetAccept())
+}
+
+func TestOutbox_RetriesFailedDeliveries(t *testing.T) {
+	net := &network{errs: []error{
+		connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+		connect.NewError(connect.CodeDeadlineExceeded, errors.New("timeout")),
+	}}
+	store := NewMemoryStore()
+	box := New(store, net.payments(), nil, WithRetryBackoff(10*time.Millisecond, time.Second))
+	startOutbox(t, box)
+
+	require.NoError(t, box.FinalizePayout(context.Background(), finalizePayout(7)))
+
+	require.Eventually(t, func() bool {
+		entries, err := store.List(context.Background())
+		return err == nil && len(entries) == 1 && entries[0].Attempts == 1
+	}, time.Second, time.Millisecond)
+	entries, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Contains(t, entries[0].LastError, "unavailable")
+
+	waitForEmptyStore(t, store)
+	assert.Len(t, net.calls(), 1)
+}
+
+func TestOutbox_GivesUpRejectedCalls(t *testing.T) {
+	tests := map[string]struct {
+		errs []error
+		opts []Option
+	}{
+		"rejected by the network": {
+			errs: []error{connect.NewError(connect.CodeInvalidArgument, errors.New("invalid"))},
+		},
+		"out of attempts": {
+			errs: []error{
+				connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+				connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+			},
+			opts: []Option{WithMaxAttempts(2)},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			net := &network{errs: tt.errs}
+			store := NewMemoryStore()
+			failed := make(chan Entry, 1)
+			opts := append([]Option{
+				WithRetryBackoff(time.Millisecond, time.Millisecond),
+				WithFailedHook(func(_ context.Context, entry Entry, _ error) { failed <- entry }),
+			}, tt.opts...)
+			box := New(store, net.payments(), nil, opts...)
+			startOutbox(t, box)
+
+			require.NoError(t, box.FinalizePayout(context.Background(), finalizePayout(3)))
+
+			select {
+			case entry := <-failed:
+				assert.Equal(t, KindFinalizePayout, entry.Kind)
+				assert.Equal(t, len(tt.errs), entry.Attempts)
+			case <-time.After(time.Second):
+				t.Fatal("entry was not given up")
+			}
+			waitForEmptyStore(t, store)
+			assert.Empty(t, net.calls())
+		})
+	}
+}
+
+func TestOutbox_AlreadyExistsIsDelivered(t *testing.T) {
+	net := &network{errs: []error{connect.NewError(connect.CodeAlreadyExists, errors.New("already finalized"))}}
+	store := NewMemoryStore()
+	delivered := make(chan proto.Message, 1)
+	box := New(store, net.payments(), nil,
+		WithDeliveredHook(func(_ context.Context, _ Entry, response proto.Message) { delivered <- response }),
+		WithFailedHook(func(_ context.Context, entry Entry, err error) {
+			t.Errorf("entry %s failed: %v", entry.Key, err)
+		}),
+	)
+	startOutbox(t, box)
+
+	require.NoError(t, box.FinalizePayout(context.Background(), finalizePayout(5)))
+
+	select {
+	case response := <-delivered:
+		assert.Nil(t, response)
+	case <-time.After(time.Second):
+		t.Fatal("entry was not delivered")
+	}
+	waitForEmptyStore(t, store)
+}
+
+func TestOutbox_ReplaysStoredEntries(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	// Enqueued by a process which crashed before delivering it
+	ctx := context.Background()
+	require.NoError(t, New(store, (&network{}).payments(), nil).FinalizePayout(ctx, finalizePayout(42)))
+
+	store, err = NewFileStore(dir)
+	require.NoError(t, err)
+	net := &network{}
+	startOutbox(t, New(store, net.payments(), nil))
+
+	waitForEmptyStore(t, store)
+	require.Len(t, net.calls(), 1)
+	assert.Equal(t, uint64(42), net.calls()[0].(*payment.FinalizePayoutRequest).PaymentId)
+}
+
+func TestOutbox_RejectsInvalidCalls(t *testing.T) {
+	box := New(NewMemoryStore(), (&network{}).payments(), nil)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, box.FinalizePayout(ctx, &payment.FinalizePayoutRequest{}), ErrInvalidRequest)
+	assert.ErrorIs(t, box.ConfirmFundsReceived(ctx, &payment_intent.ConfirmFundsReceivedRequest{PaymentIntentId: 1}), ErrNoClient)
+
+	require.NoError(t, box.Stop(ctx))
+	assert.ErrorIs(t, box.Start(ctx), ErrOutboxStopped)
+}
@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the network procedure an entry is delivered to.
+type Kind string
+
+const (
+	// KindFinalizePayout entries are delivered with
+	// payment.NetworkService.FinalizePayout.
+	KindFinalizePayout Kind = "finalize-payout"
+	// KindConfirmFundsReceived entries are delivered with
+	// payment_intent.PaymentIntentService.ConfirmFundsReceived.
+	KindConfirmFundsReceived Kind = "confirm-funds-received"
+)
+
+// Entry is an outbound call waiting for its delivery.
+type Entry struct {
+	// Key identifies the entry, it is derived from the kind and the payment
+	// or payment intent ID.
+	Key string `json:"key"`
+	// Kind of the call.
+	Kind Kind `json:"kind"`
+	// Request is the call request, in the protobuf binary format.
+	Request []byte `json:"request"`
+	// CreatedAt is when the entry was enqueued.
+	CreatedAt time.Time `json:"created_at"`
+	// Attempts is the number of failed deliveries.
+	Attempts int `json:"attempts"`
+	// NextAttemptAt is when the next delivery is due.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// LastError is the error of the last failed delivery.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Store persists the entries of an outbox. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Add stores a new entry. It fails with ErrDuplicateEntry if an entry
+	// with the same key is stored.
+	Add(ctx context.Context, entry Entry) error
+	// Update replaces a stored entry, after a failed delivery.
+	Update(ctx context.Context, entry Entry) error
+	// Delete removes an entry, once it is delivered. Deleting a missing entry
+	// is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the stored entries, oldest first.
+	List(ctx context.Context) ([]Entry, error)
+}
+
+// MemoryStore is a Store keeping the entries in memory, for tests and for
+// processes which do not need to survive a crash.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]Entry)}
+}
+
+func (s *MemoryStore) Add(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[entry.Key]; ok {
+		return ErrDuplicateEntry
+	}
+	s.entries[entry.Key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[entry.Key]; !ok {
+		return ErrEntryNotFound
+	}
+	s.entries[entry.Key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) List(context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// sortEntries sorts the entries oldest first.
+func sortEntries(entries []Entry) {
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+}
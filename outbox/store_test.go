@@ -0,0 +1,106 @@
+package outbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"memory": func(*testing.T) Store { return NewMemoryStore() },
+		"file": func(t *testing.T) Store {
+			store, err := NewFileStore(filepath.Join(t.TempDir(), "outbox"))
+			require.NoError(t, err)
+			return store
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+
+			second := Entry{Key: "finalize-payout-2", Kind: KindFinalizePayout, Request: []byte{2}, CreatedAt: now.Add(time.Second)}
+			first := Entry{Key: "finalize-payout-1", Kind: KindFinalizePayout, Request: []byte{1}, CreatedAt: now}
+			require.NoError(t, store.Add(ctx, second))
+			require.NoError(t, store.Add(ctx, first))
+			assert.ErrorIs(t, store.Add(ctx, first), ErrDuplicateEntry)
+
+			entries, err := store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			assert.Equal(t, "finalize-payout-1", entries[0].Key)
+			assert.Equal(t, []byte{1}, entries[0].Request)
+
+			first.Attempts, first.LastError = 1, "unavailable"
+			require.NoError(t, store.Update(ctx, first))
+			assert.ErrorIs(t, store.Update(ctx, Entry{Key: "finalize-payout-3"}), ErrEntryNotFound)
+
+			entries, err = store.List(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, 1, entries[0].Attempts)
+			assert.Equal(t, "unavailable", entries[0].LastError)
+
+			require.NoError(t, store.Delete(ctx, first.Key))
+			require.NoError(t, store.Delete(ctx, first.Key))
+			entries, err = store.List(ctx)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, second.Key, entries[0].Key)
+		})
+	}
+}
+
+func TestFileStore_IgnoresTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+
+	// Left by a crash while writing an entry
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".entry-123"), []byte("{"), 0o600))
+
+	entries, err := store.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	assert.ErrorIs(t, store.Add(context.Background(), Entry{Key: "../escape"}), ErrInvalidEntryKey)
+}
+
+func TestFileStore_MovesAsideCorruptEntries(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := make(map[string]error)
+	store, err := NewFileStore(dir, WithCorruptEntryHandler(func(path string, err error) {
+		corrupt[path] = err
+	}))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Add(ctx, Entry{Key: "finalize-payout-1", Kind: KindFinalizePayout}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "finalize-payout-2.json"), []byte("{"), 0o600))
+	require.NoError(t, store.Add(ctx, Entry{Key: "finalize-payout-3", Kind: KindFinalizePayout}))
+
+	entries, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "finalize-payout-1", entries[0].Key)
+	assert.Equal(t, "finalize-payout-3", entries[1].Key)
+
+	corruptPath := filepath.Join(dir, "finalize-payout-2.corrupt")
+	require.Contains(t, corrupt, corruptPath)
+	assert.Error(t, corrupt[corruptPath])
+	assert.FileExists(t, corruptPath)
+
+	// Reported once
+	clear(corrupt)
+	entries, err = store.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Empty(t, corrupt)
+}
@@ -0,0 +1,171 @@
+package provider
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Paths served by the admin listener
+const (
+	DefaultPprofPath  = "/debug/pprof/"
+	DefaultExpvarPath = "/debug/vars"
+	DefaultConfigPath = "/debug/config"
+)
+
+type adminOptions struct {
+	addr  string
+	token string
+}
+
+// WithAdminAddr serves debugging endpoints on a separate admin listener bound
+// to addr, started by StartServer alongside the server itself:
+//   - DefaultPprofPath: the net/http/pprof profiles
+//   - DefaultExpvarPath: the expvar variables
+//   - DefaultConfigPath: a JSON dump of the effective configuration, such as
+//     the trusted network public keys, body caps and registered procedures,
+//     with secrets redacted
+//
+// The handlers are only described if the handler passed to StartServer is the
+// one returned by NewHttpHandler. If WithMetrics is bound to the same address,
+// the metrics are served by the admin listener as well.
+//
+// Note that the pprof and expvar packages register their handlers on
+// http.DefaultServeMux, which must not be served publicly.
+func WithAdminAddr(addr string) ServerOption {
+	return func(opts *serverOptions) {
+		if opts.admin == nil {
+			opts.admin = &adminOptions{}
+		}
+		opts.admin.addr = addr
+	}
+}
+
+// WithAdminToken requires the requests to the admin listener to send the
+// token in an "Authorization: Bearer <token>" header. An empty token disables
+// the check.
+func WithAdminToken(token string) ServerOption {
+	return func(opts *serverOptions) {
+		if opts.admin == nil {
+			opts.admin = &adminOptions{}
+		}
+		opts.admin.token = token
+	}
+}
+
+// startAdminServers starts the admin listener and the metrics one, which are
+// merged if they are bound to the same address, in the serve group.
+func startAdminServers(opts *serverOptions, config effectiveConfig, group *serveGroup) ([]*http.Server, error) {
+	var metricsHandler http.Handler
+	if opts.metrics != nil {
+		metricsHandler = promhttp.HandlerFor(opts.metrics.registry, promhttp.HandlerOpts{})
+	}
+
+	if opts.admin == nil || opts.admin.addr == "" {
+		if metricsHandler == nil {
+			return nil, nil
+		}
+		server, err := startMetricsServer(opts.metrics, opts.readHeaderTimeout, group)
+		if err != nil {
+			return nil, err
+		}
+		return []*http.Server{server}, nil
+	}
+
+	var servers []*http.Server
+	mux := newAdminMux(config)
+	if metricsHandler != nil {
+		if opts.metrics.addr == opts.admin.addr {
+			mux.Handle(DefaultMetricsPath, metricsHandler)
+		} else {
+			server, err := startMetricsServer(opts.metrics, opts.readHeaderTimeout, group)
+			if err != nil {
+				return nil, err
+			}
+			servers = append(servers, server)
+		}
+	}
+
+	server, err := startAdminServer(opts.admin.addr, requireBearerToken(opts.admin.token)(mux), opts.readHeaderTimeout, group)
+	if err != nil {
+		for _, s := range servers {
+			s.Close()
+		}
+		return nil, err
+	}
+	return append(servers, server), nil
+}
+
+func newAdminMux(config effectiveConfig) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(DefaultPprofPath, pprof.Index)
+	mux.HandleFunc(DefaultPprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(DefaultPprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(DefaultPprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(DefaultPprofPath+"trace", pprof.Trace)
+	mux.Handle(DefaultExpvarPath, expvar.Handler())
+	mux.HandleFunc(DefaultConfigPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(config)
+	})
+	return mux
+}
+
+// requireBearerToken rejects requests without the bearer token, if any.
+func requireBearerToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if token == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// startAdminServer serves handler on addr in the serve group, see serveAdmin.
+func startAdminServer(
+	addr string, handler http.Handler, readHeaderTimeout time.Duration, group *serveGroup,
+) (*http.Server, error) {
+	listener, err := listen(addr)
+	if err != nil {
+		return nil, err
+	}
+	return serveAdmin(listener, handler, readHeaderTimeout, group), nil
+}
+
+// serveAdmin serves handler on the listener in the serve group, which reports
+// its failure. It has no write timeout, so CPU profiles and traces can be
+// longer than the provider server write timeout.
+func serveAdmin(listener net.Listener, handler http.Handler, readHeaderTimeout time.Duration, group *serveGroup) *http.Server {
+	server := &http.Server{
+		Addr:              listener.Addr().String(),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	group.serve(func() error {
+		if err := server.Serve(listener); err != nil {
+			return fmt.Errorf("serving admin listener on %s: %w", server.Addr, err)
+		}
+		return nil
+	})
+
+	return server
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+)
+
+// adminAddr returns the address of the admin server of srv, or of its
+// metrics server without admin server.
+func adminAddr(srv *RunningServer) string {
+	return srv.admin[len(srv.admin)-1].Addr
+}
+
+// adminGet sends a GET request to the admin listener, with the bearer token if not empty.
+func adminGet(t *testing.T, url, token string) (int, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, body
+}
+
+func TestWithAdminAddr_ServesEffectiveConfig(t *testing.T) {
+	_, publicKey := newTestNetworkKey(t)
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(updateLimitProvider{}),
+			WithMaxBodySize(64*1024),
+			WithConcurrencyLimit(8, time.Second),
+			WithProcedureOptions(paymentconnect.ProviderServiceAppendLedgerEntriesProcedure,
+				WithProcedureMaxBodySize(16*1024*1024),
+				WithProcedureTimeout(30*time.Second),
+			),
+		),
+	)
+	require.NoError(t, err)
+
+	srv := startServerT(t, handler, WithAdminAddr("127.0.0.1:0"), WithAdminToken("s3cr3t"))
+	adminAddr := adminAddr(srv)
+
+	status, body := adminGet(t, "http://"+adminAddr+DefaultConfigPath, "s3cr3t")
+	require.Equal(t, http.StatusOK, status)
+	assert.NotContains(t, string(body), "s3cr3t")
+
+	var config effectiveConfig
+	require.NoError(t, json.Unmarshal(body, &config))
+
+	assert.Equal(t, srv.Addr().String(), config.Server.Addr)
+	assert.Equal(t, redacted, config.Server.AdminToken)
+
+	require.Len(t, config.Handlers, 1)
+	h := config.Handlers[0]
+	assert.Equal(t, "/tzero.v1.payment.ProviderService/", h.Path)
+	assert.Equal(t, signatureVerificationNetworkKey, h.SignatureVerification)
+	assert.Equal(t, string(publicKey), h.NetworkPublicKey)
+
+	procedures := make(map[string]procedureConfig)
+	for _, p := range h.Procedures {
+		procedures[p.Procedure] = p
+	}
+	require.Contains(t, procedures, paymentconnect.ProviderServicePayOutProcedure)
+	require.Contains(t, procedures, paymentconnect.ProviderServiceAppendLedgerEntriesProcedure)
+
+	payOut := procedures[paymentconnect.ProviderServicePayOutProcedure]
+	assert.Equal(t, int64(64*1024), payOut.MaxBodySize)
+	assert.Empty(t, payOut.Timeout)
+	assert.Equal(t, &concurrencyLimitConfig{MaxInFlight: 8, QueueTimeout: "1s"}, payOut.ConcurrencyLimit)
+
+	ledger := procedures[paymentconnect.ProviderServiceAppendLedgerEntriesProcedure]
+	assert.Equal(t, int64(16*1024*1024), ledger.MaxBodySize)
+	assert.Equal(t, "30s", ledger.Timeout)
+}
+
+func TestWithAdminAddr_ServesDebugEndpoints(t *testing.T) {
+	adminAddr := adminAddr(startServerT(t, okHandler, WithAdminAddr("127.0.0.1:0")))
+
+	status, body := adminGet(t, "http://"+adminAddr+DefaultPprofPath, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "goroutine")
+
+	status, body = adminGet(t, "http://"+adminAddr+DefaultExpvarPath, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, string(body), "memstats")
+
+	// Handlers not built by NewHttpHandler are not described
+	status, body = adminGet(t, "http://"+adminAddr+DefaultConfigPath, "")
+	require.Equal(t, http.StatusOK, status)
+	var config effectiveConfig
+	require.NoError(t, json.Unmarshal(body, &config))
+	assert.Empty(t, config.Handlers)
+	assert.Empty(t, config.Server.AdminToken)
+}
+
+func TestWithAdminToken_RejectsUnauthenticatedRequests(t *testing.T) {
+	adminAddr := adminAddr(startServerT(t, okHandler,
+		WithAdminAddr("127.0.0.1:0"),
+		WithAdminToken("s3cr3t"),
+		// Shares the admin listener, behind the same token
+		WithMetrics("127.0.0.1:0", prometheus.NewRegistry()),
+	))
+
+	for _, path := range []string{DefaultConfigPath, DefaultPprofPath, DefaultExpvarPath, DefaultMetricsPath} {
+		status, _ := adminGet(t, "http://"+adminAddr+path, "")
+		assert.Equal(t, http.StatusUnauthorized, status, path)
+
+		status, _ = adminGet(t, "http://"+adminAddr+path, "wrong")
+		assert.Equal(t, http.StatusUnauthorized, status, path)
+
+		status, _ = adminGet(t, "http://"+adminAddr+path, "s3cr3t")
+		assert.Equal(t, http.StatusOK, status, path)
+	}
+}
+
+func TestServeAdmin_ReportsServeFailure(t *testing.T) {
+	listener := newFailingListener(t)
+	group := newServeGroup()
+
+	server := serveAdmin(listener, http.NotFoundHandler(), time.Second, group)
+	group.closeWhenDone()
+	t.Cleanup(func() { server.Close() })
+
+	listener.fail.Store(true)
+	wakeAccept(listener)
+
+	select {
+	case err := <-group.errs:
+		assert.ErrorIs(t, err, errAcceptFailed)
+		assert.Contains(t, err.Error(), "admin")
+	case <-time.After(5 * time.Second):
+		t.Fatal("admin server failure not reported")
+	}
+	_, open := <-group.errs
+	assert.False(t, open)
+}
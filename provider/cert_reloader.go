@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type tlsCertFilesOptions struct {
+	certPath       string
+	keyPath        string
+	reloadInterval time.Duration
+	onReloadError  func(error)
+}
+
+// certReloader serves the certificate loaded from a pair of PEM files and
+// reloads it when the files change or on SIGHUP. If a reload fails, the
+// previous certificate keeps being served and the error is reported.
+type certReloader struct {
+	opts tlsCertFilesOptions
+	cert atomic.Pointer[tls.Certificate]
+
+	mu          sync.Mutex
+	lastChecked time.Time
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// newCertReloader returns a reloader of the files, which must hold a valid
+// certificate to begin with.
+func newCertReloader(opts tlsCertFilesOptions) (*certReloader, error) {
+	r := &certReloader{opts: opts}
+	if err := r.reload(); err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. The files are checked
+// for changes at most once per reload interval.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.reloadIfChanged()
+
+	cert := r.cert.Load()
+	if cert == nil {
+		return nil, fmt.Errorf("no TLS certificate loaded from %s", r.opts.certPath)
+	}
+	return cert, nil
+}
+
+// reload unconditionally loads the certificate from the files.
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reloadLocked()
+}
+
+func (r *certReloader) reloadLocked() error {
+	r.lastChecked = time.Now()
+
+	certInfo, err := os.Stat(r.opts.certPath)
+	if err != nil {
+		return fmt.Errorf("reading TLS certificate file: %w", err)
+	}
+	keyInfo, err := os.Stat(r.opts.keyPath)
+	if err != nil {
+		return fmt.Errorf("reading TLS key file: %w", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.opts.certPath, r.opts.keyPath)
+	if err != nil {
+		return fmt.Errorf("loading TLS key pair: %w", err)
+	}
+
+	r.cert.Store(&cert)
+	r.certModTime, r.keyModTime = certInfo.ModTime(), keyInfo.ModTime()
+	return nil
+}
+
+func (r *certReloader) reloadIfChanged() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastChecked) < r.opts.reloadInterval {
+		return
+	}
+	r.lastChecked = time.Now()
+
+	certInfo, err := os.Stat(r.opts.certPath)
+	if err != nil {
+		r.reportError(fmt.Errorf("reading TLS certificate file: %w", err))
+		return
+	}
+	keyInfo, err := os.Stat(r.opts.keyPath)
+	if err != nil {
+		r.reportError(fmt.Errorf("reading TLS key file: %w", err))
+		return
+	}
+	if certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return
+	}
+
+	if err := r.reloadLocked(); err != nil {
+		r.reportError(err)
+	}
+}
+
+// watch reloads the certificate on SIGHUP and polls the files for changes,
+// until ctx is done. The SIGHUP handler is registered before watch returns.
+func (r *certReloader) watch(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sighup)
+
+		ticker := time.NewTicker(r.opts.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sighup:
+				if err := r.reload(); err != nil {
+					r.reportError(err)
+				}
+			case <-ticker.C:
+				r.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+func (r *certReloader) reportError(err error) {
+	if r.opts.onReloadError != nil {
+		r.opts.onReloadError(err)
+	}
+}
+
+// tlsConfig returns a copy of base, or a new config if base is nil, serving
+// the certificate of the reloader.
+func (r *certReloader) tlsConfig(base *tls.Config) *tls.Config {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	config.Certificates = nil
+	config.GetCertificate = r.GetCertificate
+	return config
+}
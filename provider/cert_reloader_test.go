@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a self-signed certificate for localhost, PEM encoded.
+func newTestCertificate(t *testing.T, serial int64) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestCertificate writes a new certificate to the files, with a
+// modification time distinct from the previous one.
+func writeTestCertificate(t *testing.T, certPath, keyPath string, serial int64) {
+	t.Helper()
+
+	certPEM, keyPEM := newTestCertificate(t, serial)
+	require.NoError(t, os.WriteFile(certPath, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyPath, keyPEM, 0o600))
+
+	modTime := time.Now().Add(time.Duration(serial) * time.Second)
+	require.NoError(t, os.Chtimes(certPath, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+// servedSerial returns the serial number of the certificate served on addr.
+func servedSerial(t *testing.T, addr string) int64 {
+	t.Helper()
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+}
+
+func startTLSReloadServer(t *testing.T, opts ...ServerOption) (addr, certPath, keyPath string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	certPath, keyPath = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certPath, keyPath, 1)
+
+	srv := startServerT(t, okHandler, append([]ServerOption{WithTLSCertFiles(certPath, keyPath)}, opts...)...)
+	return srv.Addr().String(), certPath, keyPath
+}
+
+func TestWithTLSCertFiles_ReloadsChangedFiles(t *testing.T) {
+	addr, certPath, keyPath := startTLSReloadServer(t, WithCertReloadInterval(10*time.Millisecond))
+	require.Equal(t, int64(1), servedSerial(t, addr))
+
+	writeTestCertificate(t, certPath, keyPath, 2)
+
+	assert.Eventually(t, func() bool {
+		return servedSerial(t, addr) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestWithTLSCertFiles_KeepsCertificateOnReloadError(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		reloadErrs []error
+	)
+	addr, certPath, _ := startTLSReloadServer(t,
+		WithCertReloadInterval(10*time.Millisecond),
+		WithCertReloadErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reloadErrs = append(reloadErrs, err)
+		}),
+	)
+
+	require.NoError(t, os.WriteFile(certPath, []byte("not a certificate"), 0o600))
+	modTime := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(certPath, modTime, modTime))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reloadErrs) > 0
+	}, 5*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, int64(1), servedSerial(t, addr))
+}
+
+func TestWithTLSCertFiles_MissingFiles(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	dir := t.TempDir()
+	certFiles := WithTLSCertFiles(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.key"))
+	srv, err := StartServer(handler, WithAddr(":0"), certFiles)
+	assert.Error(t, err)
+	assert.Nil(t, srv)
+	assert.Contains(t, err.Error(), "loading TLS certificate")
+
+	server, buildErr := BuildServer(handler, certFiles)
+	assert.EqualError(t, buildErr, err.Error())
+	assert.Nil(t, server)
+
+	server = NewServer(handler, certFiles)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestWithTLSCertFiles_KeepsTLSConfig(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certPath, keyPath, 1)
+
+	baseConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+	server := NewServer(handler, WithTLSConfig(baseConfig), WithTLSCertFiles(certPath, keyPath))
+
+	require.NotNil(t, server.TLSConfig)
+	assert.Equal(t, uint16(tls.VersionTLS13), server.TLSConfig.MinVersion)
+	assert.NotNil(t, server.TLSConfig.GetCertificate)
+	assert.Nil(t, baseConfig.GetCertificate, "the provided config must not be modified")
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package provider
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTLSCertFiles_ReloadsOnSIGHUP(t *testing.T) {
+	// A long interval, so only SIGHUP triggers the reload
+	addr, certPath, keyPath := startTLSReloadServer(t, WithCertReloadInterval(time.Hour))
+	require.Equal(t, int64(1), servedSerial(t, addr))
+
+	writeTestCertificate(t, certPath, keyPath, 2)
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool {
+		return servedSerial(t, addr) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type clientAuthOptions struct {
+	clientCAs       *x509.CertPool
+	allowedSubjects []string
+	allowedSPKIs    []string
+}
+
+// WithClientCAs requires every client to present a certificate signed by one
+// of the given CAs, in addition to the application level request signature.
+// Requires TLS, configured with WithTLSConfig or WithTLSCertFiles.
+func WithClientCAs(pool *x509.CertPool) ServerOption {
+	return func(opts *serverOptions) {
+		if opts.clientAuth == nil {
+			opts.clientAuth = &clientAuthOptions{}
+		}
+		opts.clientAuth.clientCAs = pool
+	}
+}
+
+// WithAllowedClientSubjects pins the allowed client certificates by subject.
+// A subject matches either the common name or the full distinguished name,
+// as formatted by pkix.Name.String, of the leaf certificate. The pins narrow
+// down the certificates signed by the client CAs, which must be set with
+// WithClientCAs or the ClientCAs of WithTLSConfig, otherwise the server fails
+// to start with ErrClientCAsRequired: anyone can get a certificate for any
+// subject from a public CA.
+func WithAllowedClientSubjects(subjects ...string) ServerOption {
+	return func(opts *serverOptions) {
+		if opts.clientAuth == nil {
+			opts.clientAuth = &clientAuthOptions{}
+		}
+		opts.clientAuth.allowedSubjects = append(opts.clientAuth.allowedSubjects, subjects...)
+	}
+}
+
+// WithAllowedClientSPKIHashes pins the allowed client certificates by public
+// key. Hashes are base64 encoded SHA-256 digests of the DER encoded
+// SubjectPublicKeyInfo of the leaf certificate, as returned by SPKIHash. As
+// for WithAllowedClientSubjects, the client CAs must be set.
+func WithAllowedClientSPKIHashes(hashes ...string) ServerOption {
+	return func(opts *serverOptions) {
+		if opts.clientAuth == nil {
+			opts.clientAuth = &clientAuthOptions{}
+		}
+		opts.clientAuth.allowedSPKIs = append(opts.clientAuth.allowedSPKIs, hashes...)
+	}
+}
+
+// SPKIHash returns the base64 encoded SHA-256 digest of the
+// SubjectPublicKeyInfo of the certificate, the format used by
+// WithAllowedClientSPKIHashes.
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfig returns a copy of base requiring and verifying client
+// certificates. Certificates are never verified against the system roots.
+func (c *clientAuthOptions) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	if c.clientCAs != nil {
+		config.ClientCAs = c.clientCAs
+	}
+	if config.ClientCAs == nil {
+		return nil, ErrClientCAsRequired
+	}
+
+	verifyConnection := config.VerifyConnection
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		return c.verifyPins(state)
+	}
+
+	return config, nil
+}
+
+// verifyPins checks the leaf certificate against the pinned subjects and
+// public keys. A certificate is allowed if it matches any of the pins, or if
+// no pins are configured.
+func (c *clientAuthOptions) verifyPins(state tls.ConnectionState) error {
+	if len(c.allowedSubjects) == 0 && len(c.allowedSPKIs) == 0 {
+		return nil
+	}
+	if len(state.PeerCertificates) == 0 {
+		return ErrClientCertificateNotAllowed
+	}
+
+	leaf := state.PeerCertificates[0]
+	if slices.Contains(c.allowedSubjects, leaf.Subject.CommonName) ||
+		slices.Contains(c.allowedSubjects, leaf.Subject.String()) ||
+		slices.Contains(c.allowedSPKIs, SPKIHash(leaf)) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrClientCertificateNotAllowed, leaf.Subject.String())
+}
+
+// ClientCertificateInfo describes the verified client certificate of a
+// mutual TLS connection.
+type ClientCertificateInfo struct {
+	Subject      string
+	CommonName   string
+	SerialNumber string
+	SPKIHash     string
+	NotAfter     time.Time
+	Certificate  *x509.Certificate
+}
+
+type clientCertificateContextKey struct{}
+
+// ClientCertificateFromContext returns the verified client certificate of the
+// request. It is available to provider service handlers of requests received
+// over mutual TLS, alongside the signature verification result.
+func ClientCertificateFromContext(ctx context.Context) (*ClientCertificateInfo, bool) {
+	info, ok := ctx.Value(clientCertificateContextKey{}).(*ClientCertificateInfo)
+	return info, ok
+}
+
+// withClientCertificate stores the verified client certificate of the
+// request, if any, in the returned context.
+func withClientCertificate(ctx context.Context, req *http.Request) context.Context {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+
+	leaf := req.TLS.VerifiedChains[0][0]
+	return context.WithValue(ctx, clientCertificateContextKey{}, &ClientCertificateInfo{
+		Subject:      leaf.Subject.String(),
+		CommonName:   leaf.Subject.CommonName,
+		SerialNumber: leaf.SerialNumber.String(),
+		SPKIHash:     SPKIHash(leaf),
+		NotAfter:     leaf.NotAfter,
+		Certificate:  leaf,
+	})
+}
@@ -0,0 +1,219 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// clientCertProvider records the client certificate seen by the handler.
+type clientCertProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+	certs chan *ClientCertificateInfo
+}
+
+func (p *clientCertProvider) PayOut(
+	ctx context.Context, _ *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	info, _ := ClientCertificateFromContext(ctx)
+	p.certs <- info
+	return connect.NewResponse(&payment.PayoutResponse{}), nil
+}
+
+type mtlsFixture struct {
+	privateKey network.PrivateKeyHexed
+	addr       string
+	serverCAs  *x509.CertPool
+	clientCert tls.Certificate
+	clientLeaf *x509.Certificate
+	svc        *clientCertProvider
+}
+
+// startMTLSServer starts a provider server requiring a client certificate,
+// with extra options built from the certificate the client will present.
+func startMTLSServer(t *testing.T, opts func(clientLeaf *x509.Certificate) []ServerOption) *mtlsFixture {
+	t.Helper()
+
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := &clientCertProvider{certs: make(chan *ClientCertificateInfo, 1)}
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc)),
+	)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certPath, keyPath, 1)
+	serverPEM, err := os.ReadFile(certPath)
+	require.NoError(t, err)
+	serverCAs := x509.NewCertPool()
+	require.True(t, serverCAs.AppendCertsFromPEM(serverPEM))
+
+	clientPEM, clientKeyPEM := newTestCertificate(t, 2)
+	clientCert, err := tls.X509KeyPair(clientPEM, clientKeyPEM)
+	require.NoError(t, err)
+	clientLeaf, err := x509.ParseCertificate(clientCert.Certificate[0])
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientLeaf)
+
+	serverOpts := []ServerOption{WithTLSCertFiles(certPath, keyPath), WithClientCAs(clientCAs)}
+	if opts != nil {
+		serverOpts = append(serverOpts, opts(clientLeaf)...)
+	}
+
+	addr := startServerT(t, handler, serverOpts...).Addr().String()
+
+	return &mtlsFixture{
+		privateKey: privateKey,
+		addr:       addr,
+		serverCAs:  serverCAs,
+		clientCert: clientCert,
+		clientLeaf: clientLeaf,
+		svc:        svc,
+	}
+}
+
+func (f *mtlsFixture) payOut(t *testing.T, opts ...network.ClientOption) error {
+	t.Helper()
+
+	_, port, err := net.SplitHostPort(f.addr)
+	require.NoError(t, err)
+
+	// The test certificate is issued for localhost only
+	client, err := network.NewServiceClient(f.privateKey, paymentconnect.NewProviderServiceClient,
+		append([]network.ClientOption{
+			network.WithBaseURL("https://localhost:" + port),
+			network.WithRootCAs(f.serverCAs),
+		}, opts...)...,
+	)
+	require.NoError(t, err)
+
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	return err
+}
+
+func TestClientAuth_VerifiedCertificateInContext(t *testing.T) {
+	f := startMTLSServer(t, nil)
+
+	require.NoError(t, f.payOut(t, network.WithClientCertificate(f.clientCert)))
+
+	info := <-f.svc.certs
+	require.NotNil(t, info)
+	assert.Equal(t, "localhost", info.CommonName)
+	assert.Equal(t, "2", info.SerialNumber)
+	assert.Equal(t, SPKIHash(f.clientLeaf), info.SPKIHash)
+}
+
+func TestClientAuth_RejectsMissingCertificate(t *testing.T) {
+	f := startMTLSServer(t, nil)
+
+	err := f.payOut(t)
+	assert.Error(t, err)
+	assert.Empty(t, f.svc.certs, "the handler must not be called")
+}
+
+func TestClientAuth_Pinning(t *testing.T) {
+	const otherSPKIHash = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+
+	tests := []struct {
+		name    string
+		opts    func(clientLeaf *x509.Certificate) []ServerOption
+		allowed bool
+	}{
+		{
+			name: "matching common name",
+			opts: func(*x509.Certificate) []ServerOption {
+				return []ServerOption{WithAllowedClientSubjects("localhost")}
+			},
+			allowed: true,
+		},
+		{
+			name: "matching distinguished name",
+			opts: func(*x509.Certificate) []ServerOption {
+				return []ServerOption{WithAllowedClientSubjects("CN=localhost")}
+			},
+			allowed: true,
+		},
+		{
+			name: "matching public key",
+			opts: func(clientLeaf *x509.Certificate) []ServerOption {
+				return []ServerOption{WithAllowedClientSPKIHashes(otherSPKIHash, SPKIHash(clientLeaf))}
+			},
+			allowed: true,
+		},
+		{
+			name: "other subject",
+			opts: func(*x509.Certificate) []ServerOption {
+				return []ServerOption{WithAllowedClientSubjects("partner.example")}
+			},
+			allowed: false,
+		},
+		{
+			name: "other public key",
+			opts: func(*x509.Certificate) []ServerOption {
+				return []ServerOption{WithAllowedClientSPKIHashes(otherSPKIHash)}
+			},
+			allowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := startMTLSServer(t, tt.opts)
+
+			err := f.payOut(t, network.WithClientCertificate(f.clientCert))
+			if tt.allowed {
+				require.NoError(t, err)
+				assert.NotNil(t, <-f.svc.certs)
+			} else {
+				assert.Error(t, err)
+				assert.Empty(t, f.svc.certs, "the handler must not be called")
+			}
+		})
+	}
+}
+
+func TestClientAuth_RequiresTLS(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv, err := StartServer(handler, WithAddr("127.0.0.1:0"), WithClientCAs(x509.NewCertPool()))
+	assert.Error(t, err)
+	assert.Nil(t, srv)
+}
+
+func TestClientAuth_RequiresClientCAs(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCertificate(t, certPath, keyPath, 1)
+
+	for name, opt := range map[string]ServerOption{
+		"subjects":    WithAllowedClientSubjects("localhost"),
+		"public keys": WithAllowedClientSPKIHashes("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="),
+		"no pins":     WithClientCAs(nil),
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv, err := StartServer(okHandler, WithAddr("127.0.0.1:0"), WithTLSCertFiles(certPath, keyPath), opt)
+			assert.ErrorIs(t, err, ErrClientCAsRequired)
+			assert.Nil(t, srv)
+
+			server, err := BuildServer(okHandler, WithTLSCertFiles(certPath, keyPath), opt)
+			assert.ErrorIs(t, err, ErrClientCAsRequired)
+			assert.Nil(t, server)
+		})
+	}
+}
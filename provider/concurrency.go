@@ -0,0 +1,220 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/common"
+)
+
+// concurrencyBackoffRatio is the factor applied to the limit of a procedure
+// when a call shows it is overloaded.
+const concurrencyBackoffRatio = 0.9
+
+type concurrencyLimitOptions struct {
+	maxInFlight  int
+	queueTimeout time.Duration
+}
+
+// WithConcurrencyLimit limits the number of calls of each procedure handled
+// concurrently. Every procedure has its own limit, so a procedure slowed down
+// by its backend, e.g. PayOut, never starves the others, like UpdatePayment
+// acknowledgements.
+//
+// Calls over the limit wait up to queueTimeout for a slot, then are rejected
+// with CodeResourceExhausted and a Retry-After hint, so the network backs off.
+// The limit adapts between 1 and maxInFlight: it decreases when calls outlive
+// their deadline and recovers as calls complete in time.
+// A maxInFlight <= 0 means no limit.
+// It can be overridden per procedure with WithProcedureConcurrencyLimit.
+func WithConcurrencyLimit(maxInFlight int, queueTimeout time.Duration) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.concurrencyLimit = concurrencyLimitOptions{maxInFlight: maxInFlight, queueTimeout: queueTimeout}
+	}
+}
+
+// WithProcedureConcurrencyLimit overrides the handler concurrency limit.
+// If maxInFlight is <= 0, the handler-wide limit will be used.
+func WithProcedureConcurrencyLimit(maxInFlight int, queueTimeout time.Duration) ProcedureOption {
+	return func(p *procedureOptions) {
+		if maxInFlight > 0 {
+			p.concurrencyLimit = &concurrencyLimitOptions{maxInFlight: maxInFlight, queueTimeout: queueTimeout}
+		}
+	}
+}
+
+// WithoutProcedureConcurrencyLimit disables the handler concurrency limit for
+// the procedure.
+func WithoutProcedureConcurrencyLimit() ProcedureOption {
+	return func(p *procedureOptions) {
+		p.concurrencyLimit = &concurrencyLimitOptions{}
+	}
+}
+
+func (h *providerHandlerOptions) concurrencyLimitByProcedure() map[string]concurrencyLimitOptions {
+	limits := make(map[string]concurrencyLimitOptions)
+	for procedure, p := range h.procedures {
+		if p.concurrencyLimit != nil {
+			limits[procedure] = *p.concurrencyLimit
+		}
+	}
+	return limits
+}
+
+// concurrencyLimitInterceptor admits the calls of every procedure through its
+// own limiter. Limiters of procedures using the handler-wide limit are
+// created on their first call.
+func concurrencyLimitInterceptor(
+	defaultLimit concurrencyLimitOptions, limits map[string]concurrencyLimitOptions,
+) connect.UnaryInterceptorFunc {
+	var (
+		mu       sync.Mutex
+		limiters = make(map[string]*concurrencyLimiter)
+	)
+	limiterFor := func(procedure string) *concurrencyLimiter {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if limiter, ok := limiters[procedure]; ok {
+			return limiter
+		}
+		opts, ok := limits[procedure]
+		if !ok {
+			opts = defaultLimit
+		}
+		var limiter *concurrencyLimiter
+		if opts.maxInFlight > 0 {
+			limiter = newConcurrencyLimiter(procedure, opts)
+		}
+		limiters[procedure] = limiter
+		return limiter
+	}
+
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			limiter := limiterFor(req.Spec().Procedure)
+			if limiter == nil {
+				return next(ctx, req)
+			}
+
+			if err := limiter.acquire(ctx); err != nil {
+				return nil, err
+			}
+			overloaded := false
+			defer func() { limiter.release(overloaded) }()
+
+			res, err := next(ctx, req)
+			// The handler outlived its deadline
+			overloaded = errors.Is(ctx.Err(), context.DeadlineExceeded) || connect.CodeOf(err) == connect.CodeDeadlineExceeded
+			return res, err
+		}
+	}
+}
+
+// concurrencyLimiter is a FIFO semaphore whose size adapts to the outcome of
+// the calls, increasing additively and decreasing multiplicatively (AIMD).
+type concurrencyLimiter struct {
+	procedure string
+	opts      concurrencyLimitOptions
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	waiters  []chan struct{}
+}
+
+func newConcurrencyLimiter(procedure string, opts concurrencyLimitOptions) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		procedure: procedure,
+		opts:      opts,
+		limit:     float64(opts.maxInFlight),
+	}
+}
+
+func (l *concurrencyLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.inFlight < int(l.limit) && len(l.waiters) == 0 {
+		l.inFlight++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.opts.queueTimeout <= 0 {
+		l.mu.Unlock()
+		return l.shedError()
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.opts.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-timer.C:
+		err = l.shedError()
+	case <-ctx.Done():
+		err = contextError(ctx)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i := slices.Index(l.waiters, ready); i >= 0 {
+		l.waiters = slices.Delete(l.waiters, i, i+1)
+		return err
+	}
+	// The slot was handed over concurrently, give it to the next waiter
+	l.releaseLocked()
+	return err
+}
+
+// release frees the slot of a call, adapting the limit to its outcome.
+func (l *concurrencyLimiter) release(overloaded bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if overloaded {
+		l.limit = math.Max(1, l.limit*concurrencyBackoffRatio)
+	} else {
+		l.limit = math.Min(float64(l.opts.maxInFlight), l.limit+1/l.limit)
+	}
+	l.releaseLocked()
+}
+
+func (l *concurrencyLimiter) releaseLocked() {
+	// The slot goes to the next waiter, unless the limit decreased
+	if len(l.waiters) > 0 && l.inFlight <= int(l.limit) {
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+		return
+	}
+	l.inFlight--
+}
+
+func (l *concurrencyLimiter) shedError() error {
+	err := connect.NewError(connect.CodeResourceExhausted,
+		fmt.Errorf("%s is over its concurrency limit, retry later", l.procedure))
+	err.Meta().Set(common.RetryAfterHeader, strconv.Itoa(retryAfterSeconds(l.opts.queueTimeout)))
+	return err
+}
+
+// retryAfterSeconds suggests waiting as long as calls are queued, at least a second.
+func retryAfterSeconds(queueTimeout time.Duration) int {
+	return max(1, int(math.Ceil(queueTimeout.Seconds())))
+}
+
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, ctx.Err())
+	}
+	return connect.NewError(connect.CodeCanceled, ctx.Err())
+}
@@ -0,0 +1,164 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// blockingPayOutProvider blocks PayOut calls until released, like a slow
+// bank connector, while UpdatePayment returns immediately.
+type blockingPayOutProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *blockingPayOutProvider) PayOut(
+	ctx context.Context, _ *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	p.started <- struct{}{}
+	<-p.release
+	return connect.NewResponse(&payment.PayoutResponse{}), nil
+}
+
+func (p *blockingPayOutProvider) UpdatePayment(
+	ctx context.Context, _ *connect.Request[payment.UpdatePaymentRequest],
+) (*connect.Response[payment.UpdatePaymentResponse], error) {
+	return connect.NewResponse(&payment.UpdatePaymentResponse{}), nil
+}
+
+func startConcurrencyLimitedProvider(
+	t *testing.T, opts ...HandlerOption,
+) (*blockingPayOutProvider, paymentconnect.ProviderServiceClient) {
+	t.Helper()
+
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := &blockingPayOutProvider{started: make(chan struct{}, 10), release: make(chan struct{})}
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc), opts...),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(svc.release) })
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+	return svc, client
+}
+
+// startPayOut sends a PayOut call and waits for it to reach the handler.
+func startPayOut(t *testing.T, svc *blockingPayOutProvider, client paymentconnect.ProviderServiceClient) <-chan error {
+	t.Helper()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+		result <- err
+	}()
+
+	select {
+	case <-svc.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PayOut did not reach the handler")
+	}
+	return result
+}
+
+func TestConcurrencyLimit_ShedsLoadWithRetryAfter(t *testing.T) {
+	svc, client := startConcurrencyLimitedProvider(t, WithConcurrencyLimit(1, 0))
+	startPayOut(t, svc, client)
+
+	_, err := client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	require.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+
+	var connectErr *connect.Error
+	require.True(t, errors.As(err, &connectErr))
+	assert.Equal(t, "1", connectErr.Meta().Get(common.RetryAfterHeader))
+}
+
+func TestConcurrencyLimit_QueuesUntilSlotIsFree(t *testing.T) {
+	svc, client := startConcurrencyLimitedProvider(t, WithConcurrencyLimit(1, 5*time.Second))
+	first := startPayOut(t, svc, client)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+		second <- err
+	}()
+
+	// The second call is queued, it reaches the handler once the first returns
+	svc.release <- struct{}{}
+	require.NoError(t, <-first)
+	<-svc.started
+	svc.release <- struct{}{}
+	assert.NoError(t, <-second)
+}
+
+func TestConcurrencyLimit_UpdatePaymentNotStarvedByPayOut(t *testing.T) {
+	svc, client := startConcurrencyLimitedProvider(t, WithConcurrencyLimit(1, 0))
+	startPayOut(t, svc, client)
+
+	_, err := client.UpdatePayment(context.Background(), connect.NewRequest(&payment.UpdatePaymentRequest{}))
+	assert.NoError(t, err)
+}
+
+func TestConcurrencyLimit_ProcedureOverride(t *testing.T) {
+	svc, client := startConcurrencyLimitedProvider(t,
+		WithConcurrencyLimit(10, 0),
+		WithProcedureOptions(paymentconnect.ProviderServicePayOutProcedure,
+			WithProcedureConcurrencyLimit(1, 0),
+		),
+	)
+	startPayOut(t, svc, client)
+
+	_, err := client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+}
+
+func TestConcurrencyLimiter_AdaptsLimit(t *testing.T) {
+	limiter := newConcurrencyLimiter("/test", concurrencyLimitOptions{maxInFlight: 10})
+	ctx := context.Background()
+
+	// Overloaded calls decrease the limit down to a single call
+	for range 50 {
+		require.NoError(t, limiter.acquire(ctx))
+		limiter.release(true)
+	}
+	assert.Equal(t, 1.0, limiter.limit)
+
+	require.NoError(t, limiter.acquire(ctx))
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(limiter.acquire(ctx)))
+	limiter.release(false)
+
+	// Calls completing in time recover the limit up to the maximum
+	for range 200 {
+		require.NoError(t, limiter.acquire(ctx))
+		limiter.release(false)
+	}
+	assert.Equal(t, 10.0, limiter.limit)
+}
+
+func TestConcurrencyLimiter_QueueHonoursContext(t *testing.T) {
+	limiter := newConcurrencyLimiter("/test", concurrencyLimitOptions{maxInFlight: 1, queueTimeout: time.Minute})
+	require.NoError(t, limiter.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(limiter.acquire(ctx)))
+	assert.Empty(t, limiter.waiters)
+}
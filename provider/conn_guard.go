@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+)
+
+type connGuardOptions struct {
+	allowedCIDRs  []netip.Prefix
+	maxConns      int
+	maxConnsPerIP int
+}
+
+// WithAllowedCIDRs only accepts connections from the given networks, for
+// instance the T-ZERO network egress IPs. Other connections are closed before
+// any request is read. Connections over Unix sockets are always accepted.
+func WithAllowedCIDRs(prefixes ...netip.Prefix) ServerOption {
+	return func(opts *serverOptions) {
+		opts.connGuard.allowedCIDRs = append(opts.connGuard.allowedCIDRs, prefixes...)
+	}
+}
+
+// WithMaxConnections limits the number of concurrent connections, further
+// connections are closed. A limit <= 0 means no limit.
+func WithMaxConnections(limit int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.connGuard.maxConns = limit
+	}
+}
+
+// WithMaxConnectionsPerIP limits the number of concurrent connections from a
+// single client IP, further connections are closed. A limit <= 0 means no limit.
+func WithMaxConnectionsPerIP(limit int) ServerOption {
+	return func(opts *serverOptions) {
+		opts.connGuard.maxConnsPerIP = limit
+	}
+}
+
+func (o connGuardOptions) enabled() bool {
+	return len(o.allowedCIDRs) > 0 || o.maxConns > 0 || o.maxConnsPerIP > 0
+}
+
+// connGuard enforces the connection options through http.Server.ConnState,
+// which is called for new connections before anything is read from them.
+type connGuard struct {
+	opts connGuardOptions
+
+	mu    sync.Mutex
+	total int
+	perIP map[netip.Addr]int
+	conns map[net.Conn]netip.Addr // admitted connections
+}
+
+func newConnGuard(opts connGuardOptions) *connGuard {
+	return &connGuard{
+		opts:  opts,
+		perIP: make(map[netip.Addr]int),
+		conns: make(map[net.Conn]netip.Addr),
+	}
+}
+
+func (g *connGuard) connState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		if !g.admit(conn) {
+			conn.Close()
+		}
+	case http.StateHijacked, http.StateClosed:
+		g.release(conn)
+	}
+}
+
+func (g *connGuard) admit(conn net.Conn) bool {
+	if isReadinessConn(conn) {
+		return true
+	}
+
+	// Connections without an IP, over Unix sockets, are local
+	ip, hasIP := remoteIP(conn.RemoteAddr())
+	if hasIP && len(g.opts.allowedCIDRs) > 0 && !containsIP(g.opts.allowedCIDRs, ip) {
+		return false
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.opts.maxConns > 0 && g.total >= g.opts.maxConns {
+		return false
+	}
+	if hasIP && g.opts.maxConnsPerIP > 0 && g.perIP[ip] >= g.opts.maxConnsPerIP {
+		return false
+	}
+
+	g.total++
+	if hasIP {
+		g.perIP[ip]++
+	}
+	g.conns[conn] = ip
+	return true
+}
+
+func (g *connGuard) release(conn net.Conn) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ip, ok := g.conns[conn]
+	if !ok {
+		return
+	}
+	delete(g.conns, conn)
+
+	g.total--
+	if ip.IsValid() {
+		if g.perIP[ip]--; g.perIP[ip] == 0 {
+			delete(g.perIP, ip)
+		}
+	}
+}
+
+// remoteIP returns the IP of a TCP address.
+func remoteIP(addr net.Addr) (netip.Addr, bool) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+	return ip.Unmap(), ok
+}
+
+func containsIP(prefixes []netip.Prefix, ip netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(prefix netip.Prefix) bool {
+		return prefix.Contains(ip)
+	})
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyV1Header(clientIP string) []byte {
+	return []byte("PROXY TCP4 " + clientIP + " 192.0.2.1 4242 443\r\n")
+}
+
+// openConn opens a connection counted by the server, which stays idle.
+func openConn(t *testing.T, addr net.Addr, prefix []byte) net.Conn {
+	t.Helper()
+
+	conn, err := net.Dial(addr.Network(), addr.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	_, err = conn.Write(prefix)
+	require.NoError(t, err)
+	return conn
+}
+
+func TestWithAllowedCIDRs(t *testing.T) {
+	var calls atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+
+	allowed := startServerT(t, handler, WithAllowedCIDRs(netip.MustParsePrefix("127.0.0.0/8")))
+	_, err := rawGet(allowed.Addr(), nil)
+	require.NoError(t, err)
+
+	denied := startServerT(t, handler, WithAllowedCIDRs(netip.MustParsePrefix("192.0.2.0/24")))
+	_, err = rawGet(denied.Addr(), nil)
+	assert.Error(t, err)
+
+	assert.Equal(t, int32(1), calls.Load(), "denied requests must not reach the handler")
+}
+
+func TestWithAllowedCIDRs_AppliesToProxyClientAddress(t *testing.T) {
+	srv := startServerT(t, okHandler,
+		WithProxyProtocol(),
+		WithAllowedCIDRs(netip.MustParsePrefix("203.0.113.0/24")),
+	)
+
+	_, err := rawGet(srv.Addr(), proxyV1Header("203.0.113.7"))
+	assert.NoError(t, err)
+
+	_, err = rawGet(srv.Addr(), proxyV1Header("198.51.100.7"))
+	assert.Error(t, err)
+}
+
+func TestWithAllowedCIDRs_NewServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewServer(okHandler, WithAllowedCIDRs(netip.MustParsePrefix("192.0.2.0/24")))
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	_, err = rawGet(listener.Addr(), nil)
+	assert.Error(t, err)
+}
+
+func TestWithMaxConnections(t *testing.T) {
+	srv := startServerT(t, okHandler, WithMaxConnections(1))
+
+	idle := openConn(t, srv.Addr(), nil)
+	// The idle connection is counted once accepted
+	assert.Eventually(t, func() bool {
+		_, err := rawGet(srv.Addr(), nil)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	idle.Close()
+	assert.Eventually(t, func() bool {
+		_, err := rawGet(srv.Addr(), nil)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWithMaxConnectionsPerIP(t *testing.T) {
+	srv := startServerT(t, okHandler, WithProxyProtocol(), WithMaxConnectionsPerIP(1))
+
+	openConn(t, srv.Addr(), proxyV1Header("203.0.113.7"))
+	assert.Eventually(t, func() bool {
+		_, err := rawGet(srv.Addr(), proxyV1Header("203.0.113.7"))
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, err := rawGet(srv.Addr(), proxyV1Header("203.0.113.8"))
+	assert.NoError(t, err, "other client IPs must not be limited")
+}
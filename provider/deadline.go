@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// writeDeadlineContextKey carries the time at which the server aborts writing
+// the response of the request.
+type writeDeadlineContextKey struct{}
+
+// newWriteDeadlineMiddleware records the write deadline of the server in the
+// request context, so that handler deadlines expire early enough to write a
+// DeadlineExceeded error instead of having the connection cut mid-write.
+// A tenth of the write timeout is reserved for writing the response.
+func newWriteDeadlineMiddleware(writeTimeout time.Duration) middleware {
+	return func(next http.Handler) http.Handler {
+		if writeTimeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			deadline := time.Now().Add(writeTimeout - writeTimeout/10)
+			ctx := context.WithValue(r.Context(), writeDeadlineContextKey{}, deadline)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// deadlineInterceptor bounds the handler context by the earliest of:
+//   - the timeout sent by the network in the Connect-Timeout-Ms or grpc-timeout
+//     header, which connect already applied to the context,
+//   - the timeout of the procedure, falling back to the handler-wide timeout,
+//   - the write deadline of the server, if the request is served by NewServer
+//     or StartServer.
+//
+// The deadline is only propagated through the context, handlers returning the
+// context error get a DeadlineExceeded error. With cutoff, see
+// WithDeadlineCutoff, a DeadlineExceeded error is returned when the deadline
+// passes even if the handler is still running, the result of the handler is
+// then discarded.
+func deadlineInterceptor(
+	defaultTimeout time.Duration, timeouts map[string]time.Duration, cutoff bool,
+) connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			timeout, ok := timeouts[req.Spec().Procedure]
+			if !ok {
+				timeout = defaultTimeout
+			}
+
+			var cancel context.CancelFunc = func() {}
+			if timeout > 0 {
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+			}
+			if writeDeadline, ok := ctx.Value(writeDeadlineContextKey{}).(time.Time); ok {
+				var cancelWrite context.CancelFunc
+				ctx, cancelWrite = context.WithDeadline(ctx, writeDeadline)
+				cancel = chainCancel(cancel, cancelWrite)
+			}
+			defer cancel()
+
+			if _, ok := ctx.Deadline(); !ok || !cutoff {
+				return next(ctx, req)
+			}
+
+			return callWithDeadline(ctx, req, next)
+		}
+	}
+}
+
+type handlerResult struct {
+	res       connect.AnyResponse
+	err       error
+	recovered any
+}
+
+// callWithDeadline runs the handler in a separate goroutine and returns as soon
+// as either the handler returns or the context is done. Panics are propagated
+// to the calling goroutine.
+func callWithDeadline(ctx context.Context, req connect.AnyRequest, next connect.UnaryFunc) (connect.AnyResponse, error) {
+	done := make(chan handlerResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- handlerResult{recovered: r}
+			}
+		}()
+
+		res, err := next(ctx, req)
+		done <- handlerResult{res: res, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.unwrap()
+	case <-ctx.Done():
+		// Prefer the handler result if it raced with the deadline
+		select {
+		case result := <-done:
+			return result.unwrap()
+		default:
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, connect.NewError(connect.CodeDeadlineExceeded, errors.New("handler deadline exceeded"))
+		}
+		return nil, connect.NewError(connect.CodeCanceled, ctx.Err())
+	}
+}
+
+func (r handlerResult) unwrap() (connect.AnyResponse, error) {
+	if r.recovered != nil {
+		panic(r.recovered)
+	}
+	return r.res, r.err
+}
+
+func chainCancel(first, second context.CancelFunc) context.CancelFunc {
+	return func() {
+		second()
+		first()
+	}
+}
@@ -0,0 +1,169 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// stuckProvider ignores the context cancellation, like a bank call without a timeout.
+type stuckProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+	release  chan struct{}
+	deadline chan time.Duration
+	panics   bool
+}
+
+func (p *stuckProvider) PayOut(
+	ctx context.Context, _ *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		p.deadline <- time.Until(deadline)
+	}
+	if p.panics {
+		panic("bank connector crashed")
+	}
+	<-p.release
+	return connect.NewResponse(&payment.PayoutResponse{}), nil
+}
+
+func newStuckProvider() *stuckProvider {
+	return &stuckProvider{release: make(chan struct{}), deadline: make(chan time.Duration, 1)}
+}
+
+func newPayOutClient(t *testing.T, privateKey network.PrivateKeyHexed, baseURL string) paymentconnect.ProviderServiceClient {
+	t.Helper()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(baseURL),
+	)
+	require.NoError(t, err)
+	return client
+}
+
+func TestDeadline_ProcedureTimeoutReturnsDeadlineExceeded(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := newStuckProvider()
+	defer close(svc.release)
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithDeadlineCutoff(),
+			WithProcedureOptions(paymentconnect.ProviderServicePayOutProcedure,
+				WithProcedureTimeout(100*time.Millisecond),
+			),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	start := time.Now()
+	_, err = newPayOutClient(t, privateKey, server.URL).
+		PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+
+	assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestDeadline_HonoursNetworkTimeout(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := newStuckProvider()
+	defer close(svc.release)
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithHandlerTimeout(time.Minute),
+			WithDeadlineCutoff(),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The client sends its deadline in the Connect-Timeout-Ms header
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	_, err = newPayOutClient(t, privateKey, server.URL).
+		PayOut(ctx, connect.NewRequest(&payment.PayoutRequest{}))
+	assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+
+	assert.LessOrEqual(t, <-svc.deadline, 200*time.Millisecond)
+}
+
+func TestDeadline_CappedByServerWriteTimeout(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := newStuckProvider()
+	defer close(svc.release)
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithDeadlineCutoff(),
+		),
+	)
+	require.NoError(t, err)
+
+	srv := startServerT(t, handler, WithWriteTimeout(500*time.Millisecond))
+
+	_, err = newPayOutClient(t, privateKey, "http://"+srv.Addr().String()).
+		PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+
+	// Instead of the connection being cut by the server write timeout
+	assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+	assert.Less(t, <-svc.deadline, 500*time.Millisecond)
+}
+
+func TestDeadline_PanicIsRecovered(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := newStuckProvider()
+	svc.panics = true
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithHandlerTimeout(time.Second),
+			WithDeadlineCutoff(),
+			WithPanicHook(nil),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	_, err = newPayOutClient(t, privateKey, server.URL).
+		PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+}
+
+func TestDeadline_PropagatedThroughContextOnly(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+	svc := newStuckProvider()
+	time.AfterFunc(300*time.Millisecond, func() { close(svc.release) })
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithHandlerTimeout(100*time.Millisecond),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The handler ignoring its deadline is not abandoned, its result is returned
+	_, err = newPayOutClient(t, privateKey, server.URL).
+		PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, <-svc.deadline, 100*time.Millisecond)
+}
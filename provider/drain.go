@@ -0,0 +1,183 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// UnfinishedTasksError reports the background tasks still running when the
+// drain deadline passed.
+type UnfinishedTasksError struct {
+	Tasks []string
+}
+
+func (e *UnfinishedTasksError) Error() string {
+	return fmt.Sprintf("%d background tasks unfinished at shutdown: %s", len(e.Tasks), strings.Join(e.Tasks, ", "))
+}
+
+// Drainer tracks the background work started by provider service handlers,
+// such as an asynchronous bank call that must complete before FinalizePayout
+// is sent, so that shutdown waits for it.
+//
+// Register it with WithDrainer: on shutdown the drainer first reports not
+// ready, then once in-flight requests are done, waits for the background
+// tasks up to the shutdown deadline. The contexts of unfinished tasks are
+// then cancelled and the tasks are reported by an UnfinishedTasksError.
+//
+// Example:
+//
+//	drainer := provider.NewDrainer()
+//	// in a handler
+//	err := drainer.Go("payout "+paymentID, func(ctx context.Context) {
+//	    result := bank.Transfer(ctx, req)
+//	    networkClient.FinalizePayout(ctx, result)
+//	})
+type Drainer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	draining bool
+	closed   bool
+	nextID   uint64
+	tasks    map[uint64]string
+	changed  chan struct{} // closed and replaced when a task finishes
+}
+
+// NewDrainer returns an empty drainer, accepting background tasks until it is
+// drained. Pass it to StartServer or Run with WithDrainer so that their
+// shutdown drains it, and use the same drainer in the handlers starting
+// background tasks. Servers created by BuildServer or NewServer must call
+// Drain themselves once shut down.
+func NewDrainer() *Drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Drainer{
+		ctx:     ctx,
+		cancel:  cancel,
+		tasks:   make(map[uint64]string),
+		changed: make(chan struct{}),
+	}
+}
+
+// WithDrainer makes the shutdown of StartServer and Run drain the background
+// tasks tracked by the drainer, see Drainer.
+func WithDrainer(drainer *Drainer) ServerOption {
+	return func(opts *serverOptions) {
+		opts.drainer = drainer
+	}
+}
+
+// Track registers a background task, which must call done when it finishes.
+// Tasks can be registered until the drain completes, so requests still in
+// flight at shutdown can start theirs, afterwards ErrDrained is returned.
+func (d *Drainer) Track(name string) (done func(), err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrDrained
+	}
+	id := d.nextID
+	d.nextID++
+	d.tasks[id] = name
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { d.finish(id) })
+	}, nil
+}
+
+// Go runs fn as a tracked background task. The context passed to fn is not
+// bound to any request, it is cancelled if the task is still running when
+// the drain deadline passes.
+func (d *Drainer) Go(name string, fn func(ctx context.Context)) error {
+	done, err := d.Track(name)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer done()
+		fn(d.ctx)
+	}()
+	return nil
+}
+
+// Ready reports false once the drain started.
+func (d *Drainer) Ready() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return !d.draining
+}
+
+// ReadinessHandler answers 200 OK while the drainer is ready, and 503 Service
+// Unavailable once the drain started, so load balancers stop sending traffic
+// before the server stops accepting it.
+func (d *Drainer) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !d.Ready() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+}
+
+// Drain reports not ready, and waits for the background tasks until ctx is
+// done. It is called by the shutdown of servers started with WithDrainer,
+// servers created by BuildServer or NewServer must call it once shut down.
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.startDraining()
+	return d.wait(ctx)
+}
+
+func (d *Drainer) startDraining() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.draining = true
+}
+
+func (d *Drainer) wait(ctx context.Context) error {
+	for {
+		d.mu.Lock()
+		if len(d.tasks) == 0 {
+			d.closed = true
+			d.mu.Unlock()
+			d.cancel()
+			return nil
+		}
+		changed := d.changed
+		d.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			d.mu.Lock()
+			d.closed = true
+			tasks := make([]string, 0, len(d.tasks))
+			for _, name := range d.tasks {
+				tasks = append(tasks, name)
+			}
+			d.mu.Unlock()
+
+			d.cancel()
+			slices.Sort(tasks)
+			return &UnfinishedTasksError{Tasks: tasks}
+		}
+	}
+}
+
+func (d *Drainer) finish(id uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.tasks, id)
+	close(d.changed)
+	d.changed = make(chan struct{})
+}
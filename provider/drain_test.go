@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainer_WaitsForTasks(t *testing.T) {
+	drainer := NewDrainer()
+	release := make(chan struct{})
+	var finished atomic.Bool
+	require.NoError(t, drainer.Go("bank transfer", func(ctx context.Context) {
+		<-release
+		finished.Store(true)
+	}))
+
+	result := make(chan error, 1)
+	go func() { result <- drainer.Drain(context.Background()) }()
+
+	assert.Eventually(t, func() bool { return !drainer.Ready() }, time.Second, time.Millisecond)
+	// Tasks can still be started by requests in flight
+	done, err := drainer.Track("late task")
+	require.NoError(t, err)
+	done()
+
+	close(release)
+	require.NoError(t, <-result)
+	assert.True(t, finished.Load())
+
+	_, err = drainer.Track("after drain")
+	assert.ErrorIs(t, err, ErrDrained)
+}
+
+func TestDrainer_ReportsUnfinishedTasks(t *testing.T) {
+	drainer := NewDrainer()
+	cancelled := make(chan struct{})
+	require.NoError(t, drainer.Go("payout 2", func(ctx context.Context) {
+		<-ctx.Done()
+		close(cancelled)
+	}))
+	_, err := drainer.Track("payout 1")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = drainer.Drain(ctx)
+
+	var unfinished *UnfinishedTasksError
+	require.True(t, errors.As(err, &unfinished))
+	assert.Equal(t, []string{"payout 1", "payout 2"}, unfinished.Tasks)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the context of the unfinished task was not cancelled")
+	}
+}
+
+func TestDrainer_ReadinessHandler(t *testing.T) {
+	drainer := NewDrainer()
+
+	rec := httptest.NewRecorder()
+	drainer.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	require.NoError(t, drainer.Drain(context.Background()))
+
+	rec = httptest.NewRecorder()
+	drainer.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestWithDrainer_ShutdownWaitsForTasks(t *testing.T) {
+	drainer := NewDrainer()
+	release := make(chan struct{})
+	var finished atomic.Bool
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The task outlives the request
+		_ = drainer.Go("bank transfer", func(ctx context.Context) {
+			<-release
+			finished.Store(true)
+		})
+	})
+
+	var readyAtShutdown atomic.Bool
+	srv, err := StartServer(handler,
+		WithAddr("127.0.0.1:0"),
+		WithDrainer(drainer),
+		WithPreShutdownHook(func(context.Context) error {
+			readyAtShutdown.Store(drainer.Ready())
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+
+	_, err = rawGet(srv.Addr(), nil)
+	require.NoError(t, err)
+
+	result := make(chan error, 1)
+	go func() { result <- srv.Shutdown(context.Background()) }()
+
+	select {
+	case <-result:
+		t.Fatal("the shutdown did not wait for the background task")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	require.NoError(t, <-result)
+	assert.True(t, finished.Load())
+	assert.False(t, readyAtShutdown.Load(), "readiness must go false before the other shutdown steps")
+}
+
+func TestWithDrainer_ShutdownReportsUnfinishedTasks(t *testing.T) {
+	drainer := NewDrainer()
+	_, err := drainer.Track("stuck bank call")
+	require.NoError(t, err)
+
+	srv, err := StartServer(okHandler,
+		WithAddr("127.0.0.1:0"),
+		WithDrainer(drainer),
+		WithShutdownTimeout(100*time.Millisecond),
+	)
+	require.NoError(t, err)
+
+	err = srv.Shutdown(context.Background())
+	var unfinished *UnfinishedTasksError
+	require.True(t, errors.As(err, &unfinished))
+	assert.Equal(t, []string{"stuck bank call"}, unfinished.Tasks)
+}
@@ -0,0 +1,263 @@
+package provider
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// redacted replaces secrets in the effective configuration.
+const redacted = "[REDACTED]"
+
+// Signature verification modes of a handler
+const (
+	signatureVerificationNetworkKey = "network_public_key"
+	signatureVerificationCustom     = "custom"
+	signatureVerificationDisabled   = "disabled"
+)
+
+// effectiveConfig is the configuration served on the admin listener.
+type effectiveConfig struct {
+	Server   serverConfig    `json:"server"`
+	Handlers []handlerConfig `json:"handlers"`
+}
+
+type serverConfig struct {
+	Addr              string            `json:"addr"`
+	ReadTimeout       string            `json:"read_timeout"`
+	WriteTimeout      string            `json:"write_timeout"`
+	ReadHeaderTimeout string            `json:"read_header_timeout"`
+	ShutdownTimeout   string            `json:"shutdown_timeout"`
+	TLS               *tlsConfigSummary `json:"tls,omitempty"`
+	UDPServer         bool              `json:"udp_server"`
+
+	AllowedCIDRs        []string              `json:"allowed_cidrs,omitempty"`
+	MaxConnections      int                   `json:"max_connections,omitempty"`
+	MaxConnectionsPerIP int                   `json:"max_connections_per_ip,omitempty"`
+	ProxyProtocol       *proxyProtocolSummary `json:"proxy_protocol,omitempty"`
+
+	MetricsAddr string `json:"metrics_addr,omitempty"`
+	AdminAddr   string `json:"admin_addr"`
+	AdminToken  string `json:"admin_token,omitempty"`
+
+	Drainer           bool `json:"drainer"`
+	PreShutdownHooks  int  `json:"pre_shutdown_hooks"`
+	PostShutdownHooks int  `json:"post_shutdown_hooks"`
+}
+
+type tlsConfigSummary struct {
+	CertFile       string `json:"cert_file,omitempty"`
+	KeyFile        string `json:"key_file,omitempty"`
+	ReloadInterval string `json:"reload_interval,omitempty"`
+
+	ClientAuth *clientAuthSummary `json:"client_auth,omitempty"`
+}
+
+type clientAuthSummary struct {
+	ClientCAs         bool     `json:"client_cas"`
+	AllowedSubjects   []string `json:"allowed_subjects,omitempty"`
+	AllowedSPKIHashes []string `json:"allowed_spki_hashes,omitempty"`
+}
+
+type proxyProtocolSummary struct {
+	TrustedProxies []string `json:"trusted_proxies"`
+}
+
+type handlerConfig struct {
+	Path                  string            `json:"path"`
+	SignatureVerification string            `json:"signature_verification"`
+	NetworkPublicKey      string            `json:"network_public_key,omitempty"`
+	RecoverPanics         bool              `json:"recover_panics"`
+	Tracing               bool              `json:"tracing"`
+	Metrics               bool              `json:"metrics"`
+	Procedures            []procedureConfig `json:"procedures"`
+}
+
+// procedureConfig holds the options effective for a procedure, handler-wide
+// options included.
+type procedureConfig struct {
+	Procedure        string                  `json:"procedure"`
+	MaxBodySize      int64                   `json:"max_body_size"`
+	Timeout          string                  `json:"timeout,omitempty"`
+	ConcurrencyLimit *concurrencyLimitConfig `json:"concurrency_limit,omitempty"`
+	Interceptors     int                     `json:"interceptors,omitempty"`
+}
+
+type concurrencyLimitConfig struct {
+	MaxInFlight  int    `json:"max_in_flight"`
+	QueueTimeout string `json:"queue_timeout"`
+}
+
+// configuredHandler is a provider service handler built by Handler, along
+// with the configuration it was built with.
+type configuredHandler struct {
+	http.Handler
+	config handlerConfig
+}
+
+// providerMux is the handler returned by NewHttpHandler.
+type providerMux struct {
+	*http.ServeMux
+	handlers []handlerConfig
+}
+
+// describeHandlers returns the configuration of the handlers registered by
+// NewHttpHandler, or nil for other handlers.
+func describeHandlers(handler http.Handler) []handlerConfig {
+	if mux, ok := handler.(*providerMux); ok {
+		return mux.handlers
+	}
+	return nil
+}
+
+// describeHandler returns the effective configuration of the handler served
+// on path. The procedures of the service are looked up in the global proto
+// registry, procedures with overrides are listed even if not found there.
+func (h *providerHandlerOptions) describeHandler(path string) handlerConfig {
+	config := handlerConfig{
+		Path:          path,
+		RecoverPanics: h.recoverPanics,
+		Tracing:       h.telemetry.tracerProvider != nil,
+		Metrics:       h.telemetry.meterProvider != nil,
+	}
+
+	switch {
+	case h.verifySignatureFn == nil:
+		config.SignatureVerification = signatureVerificationDisabled
+	case h.customVerifySignature:
+		config.SignatureVerification = signatureVerificationCustom
+	default:
+		config.SignatureVerification = signatureVerificationNetworkKey
+		config.NetworkPublicKey = string(h.networkPublicKey)
+	}
+
+	var procedures []string
+	serviceName := protoreflect.FullName(strings.Trim(path, "/"))
+	if desc, err := protoregistry.GlobalFiles.FindDescriptorByName(serviceName); err == nil {
+		if service, ok := desc.(protoreflect.ServiceDescriptor); ok {
+			methods := service.Methods()
+			for i := range methods.Len() {
+				procedures = append(procedures, "/"+string(serviceName)+"/"+string(methods.Get(i).Name()))
+			}
+		}
+	}
+	for procedure := range h.procedures {
+		if !slices.Contains(procedures, procedure) {
+			procedures = append(procedures, procedure)
+		}
+	}
+	slices.Sort(procedures)
+
+	config.Procedures = make([]procedureConfig, 0, len(procedures))
+	for _, procedure := range procedures {
+		p := procedureConfig{
+			Procedure:        procedure,
+			MaxBodySize:      h.verifySignatureMaxBodySize,
+			Timeout:          durationString(h.timeout),
+			ConcurrencyLimit: describeConcurrencyLimit(h.concurrencyLimit),
+			Interceptors:     len(h.interceptors),
+		}
+		if o, ok := h.procedures[procedure]; ok {
+			if o.maxBodySize > 0 {
+				p.MaxBodySize = o.maxBodySize
+			}
+			if o.timeout != nil {
+				p.Timeout = durationString(*o.timeout)
+			}
+			if o.concurrencyLimit != nil {
+				p.ConcurrencyLimit = describeConcurrencyLimit(*o.concurrencyLimit)
+			}
+			if o.skipHandlerInterceptors {
+				p.Interceptors = 0
+			}
+			p.Interceptors += len(o.interceptors)
+		}
+		config.Procedures = append(config.Procedures, p)
+	}
+
+	return config
+}
+
+func describeConcurrencyLimit(limit concurrencyLimitOptions) *concurrencyLimitConfig {
+	if limit.maxInFlight <= 0 {
+		return nil
+	}
+	return &concurrencyLimitConfig{
+		MaxInFlight:  limit.maxInFlight,
+		QueueTimeout: limit.queueTimeout.String(),
+	}
+}
+
+// describeServer returns the effective server configuration, with secrets
+// redacted. Only the paths of certificate files are reported.
+func (opts *serverOptions) describeServer(addr string) serverConfig {
+	config := serverConfig{
+		Addr:                addr,
+		ReadTimeout:         durationString(opts.readTimeout),
+		WriteTimeout:        durationString(opts.writeTimeout),
+		ReadHeaderTimeout:   durationString(opts.readHeaderTimeout),
+		ShutdownTimeout:     opts.shutdownTimeout.String(),
+		UDPServer:           opts.newUDPServer != nil,
+		AllowedCIDRs:        prefixStrings(opts.connGuard.allowedCIDRs),
+		MaxConnections:      opts.connGuard.maxConns,
+		MaxConnectionsPerIP: opts.connGuard.maxConnsPerIP,
+		Drainer:             opts.drainer != nil,
+		PreShutdownHooks:    len(opts.preShutdownHooks),
+		PostShutdownHooks:   len(opts.postShutdownHooks),
+	}
+
+	if opts.tlsConfig != nil || opts.tlsCertFiles != nil {
+		config.TLS = &tlsConfigSummary{}
+		if opts.tlsCertFiles != nil {
+			config.TLS.CertFile = opts.tlsCertFiles.certPath
+			config.TLS.KeyFile = opts.tlsCertFiles.keyPath
+			config.TLS.ReloadInterval = opts.certReloadInterval.String()
+		}
+		if opts.clientAuth != nil {
+			config.TLS.ClientAuth = &clientAuthSummary{
+				ClientCAs:         opts.clientAuth.clientCAs != nil,
+				AllowedSubjects:   opts.clientAuth.allowedSubjects,
+				AllowedSPKIHashes: opts.clientAuth.allowedSPKIs,
+			}
+		}
+	}
+
+	if opts.proxyProtocol != nil {
+		config.ProxyProtocol = &proxyProtocolSummary{
+			TrustedProxies: prefixStrings(opts.proxyProtocol.trustedProxies),
+		}
+	}
+
+	if opts.metrics != nil {
+		config.MetricsAddr = opts.metrics.addr
+	}
+	if opts.admin != nil {
+		config.AdminAddr = opts.admin.addr
+		if opts.admin.token != "" {
+			config.AdminToken = redacted
+		}
+	}
+
+	return config
+}
+
+// durationString formats a timeout, 0 meaning no timeout.
+func durationString(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return d.String()
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	var s []string
+	for _, prefix := range prefixes {
+		s = append(s, prefix.String())
+	}
+	return s
+}
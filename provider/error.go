@@ -5,9 +5,18 @@ import "errors"
 var (
 	ErrMissingRequiredHeader       = errors.New("missing required header")
 	ErrInvalidHeaderEncoding       = errors.New("invalid header encoding")
+	ErrInvalidTimestampHeader      = errors.New("invalid timestamp header")
+	ErrTimestampOutsideWindow      = errors.New("timestamp is outside the allowed time window")
+	ErrMaxPayloadSizeExceeded      = errors.New("max payload size exceeded")
 	ErrUnknownPublicKey            = errors.New("request signed with unknown public key")
 	ErrSignatureVerificationFailed = errors.New("signature verification failed")
 	ErrInvalidSignature            = errors.New("invalid signature")
 	ErrNoSignatureResult           = errors.New("no signature result in context")
 	ErrNetworkPublicKeyIsRequired  = errors.New("network public key is not set")
+	ErrClientCertificateNotAllowed = errors.New("client certificate is not allowed")
+	ErrClientCAsRequired           = errors.New("client certificate authentication requires the client CAs")
+	ErrNoSystemdSocket             = errors.New("no matching socket passed by systemd")
+	ErrDrained                     = errors.New("drainer was drained, no background task can be started")
+	ErrTooManyPendingConnections   = errors.New("too many connections sending their PROXY header")
+	ErrStartServerOnly             = errors.New("option requires StartServer")
 )
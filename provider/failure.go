@@ -0,0 +1,71 @@
+package provider
+
+import (
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+)
+
+// The builders below are the provider side of the network/errors package:
+// they build the failure results sent to the network, with a message chosen
+// by the caller, which is sent as is. Do not pass the message of a Go error,
+// it may leak internal details. The failed payments notified by UpdatePayment
+// are converted to typed errors by errors.MessageError of the network/errors
+// package.
+
+// PayoutFailed returns a PayoutResponse failing the payout, with the given
+// details.
+//
+// Example:
+//
+//	if err := bank.Transfer(ctx, transfer); err != nil {
+//	    slog.ErrorContext(ctx, "payout failed", "error", err)
+//	    return connect.NewResponse(provider.PayoutFailed("beneficiary account closed")), nil
+//	}
+func PayoutFailed(details string) *payment.PayoutResponse {
+	return &payment.PayoutResponse{
+		Result: &payment.PayoutResponse_Failed_{Failed: &payment.PayoutResponse_Failed{
+			Reason:  payment.PayoutResponse_Failed_REASON_UNSPECIFIED,
+			Details: &details,
+		}},
+	}
+}
+
+// PaymentQuoteRejected returns an ApprovePaymentQuoteResponse rejecting the
+// quote.
+func PaymentQuoteRejected() *payment.ApprovePaymentQuoteResponse {
+	return &payment.ApprovePaymentQuoteResponse{
+		Result: &payment.ApprovePaymentQuoteResponse_Rejected_{Rejected: &payment.ApprovePaymentQuoteResponse_Rejected{}},
+	}
+}
+
+// PaymentDetailsRejected returns a GetPaymentDetailsResponse rejecting the
+// payment intent for the given reason.
+func PaymentDetailsRejected(reason string) *payment_intent.GetPaymentDetailsResponse {
+	return &payment_intent.GetPaymentDetailsResponse{
+		Result: &payment_intent.GetPaymentDetailsResponse_Rejection_{Rejection: &payment_intent.GetPaymentDetailsResponse_Rejection{
+			Reason: reason,
+		}},
+	}
+}
+
+// FinalizePayoutFailed returns a FinalizePayoutRequest reporting the failure
+// of the payout of the payment for the given reason.
+func FinalizePayoutFailed(paymentID uint64, reason string) *payment.FinalizePayoutRequest {
+	return &payment.FinalizePayoutRequest{
+		PaymentId: paymentID,
+		Result: &payment.FinalizePayoutRequest_Failure_{Failure: &payment.FinalizePayoutRequest_Failure{
+			Reason: reason,
+		}},
+	}
+}
+
+// ManualAmlCheckRejected returns a CompleteManualAmlCheckRequest rejecting the
+// payment after the manual AML check for the given reason.
+func ManualAmlCheckRejected(paymentID uint64, reason string) *payment.CompleteManualAmlCheckRequest {
+	return &payment.CompleteManualAmlCheckRequest{
+		PaymentId: paymentID,
+		Result: &payment.CompleteManualAmlCheckRequest_Rejected_{Rejected: &payment.CompleteManualAmlCheckRequest_Rejected{
+			Reason: reason,
+		}},
+	}
+}
@@ -0,0 +1,38 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	neterrors "github.com/t-0-network/provider-sdk-go/network/errors"
+)
+
+func TestFailureBuilders(t *testing.T) {
+	payout := PayoutFailed("account closed")
+	require.NotNil(t, payout.GetFailed())
+	assert.Equal(t, payment.PayoutResponse_Failed_REASON_UNSPECIFIED, payout.GetFailed().GetReason())
+	assert.Equal(t, "account closed", payout.GetFailed().GetDetails())
+
+	assert.NotNil(t, PaymentQuoteRejected().GetRejected())
+	assert.Equal(t, "account closed", PaymentDetailsRejected("account closed").GetRejection().GetReason())
+
+	finalize := FinalizePayoutFailed(7, "account closed")
+	assert.Equal(t, uint64(7), finalize.GetPaymentId())
+	assert.Equal(t, "account closed", finalize.GetFailure().GetReason())
+
+	aml := ManualAmlCheckRejected(8, "sanctions match")
+	assert.Equal(t, uint64(8), aml.GetPaymentId())
+	assert.Equal(t, "sanctions match", aml.GetRejected().GetReason())
+}
+
+func TestUpdatePaymentFailure(t *testing.T) {
+	req := &payment.UpdatePaymentRequest{Result: &payment.UpdatePaymentRequest_Failed_{Failed: &payment.UpdatePaymentRequest_Failed{
+		Reason: payment.UpdatePaymentRequest_Failed_REASON_NO_QUOTE_AFTER_AML_APPROVAL,
+	}}}
+
+	err := neterrors.MessageError(req)
+	assert.ErrorIs(t, err, neterrors.ErrNoQuoteAfterAmlApproval)
+	assert.False(t, neterrors.IsPermanent(err))
+}
@@ -11,7 +11,7 @@ type BuildHandler func(defaultOptions providerHandlerOptions) (path string, hand
 // T-ZERO Network Public Key, required for signature verification.
 type NetworkPublicKeyHexed string
 
-// NewHttpHandler returns a ready-to-use *http.ServeMux with the
+// NewHttpHandler returns a ready-to-use HTTP mux with the
 // networkconnect.ProviderServiceHandler registered.
 //
 // It creates a new HTTP mux, registers the provided ProviderServiceHandler on the appropriate path,
@@ -21,7 +21,8 @@ type NetworkPublicKeyHexed string
 //   - service: An implementation of the networkconnect.ProviderServiceHandler interface.
 //
 // Returns:
-//   - *http.ServeMux: An HTTP mux with the provider service handler registered.
+//   - http.Handler: An HTTP mux with the provider service handler registered, whose
+//     configuration is served by the admin listener, see WithAdminAddr.
 func NewHttpHandler(
 	networkPublicKey NetworkPublicKeyHexed,
 	buildHandlers ...BuildHandler,
@@ -38,11 +39,15 @@ func NewHttpHandler(
 	if err != nil {
 		return nil, err
 	}
+	defaultOptions.networkPublicKey = networkPublicKey
 
-	mux := http.NewServeMux()
+	mux := &providerMux{ServeMux: http.NewServeMux()}
 	for _, b := range buildHandlers {
 		path, providerServiceHandler := b(defaultOptions)
 		mux.Handle(path, providerServiceHandler)
+		if configured, ok := providerServiceHandler.(*configuredHandler); ok {
+			mux.handlers = append(mux.handlers, configured.config)
+		}
 	}
 
 	return mux, nil
@@ -53,8 +58,19 @@ func Handler[T any](handler func(svc T, option ...connect.HandlerOption) (string
 		for _, o := range options {
 			o(&defaultOptions)
 		}
-		path, h := handler(p, defaultOptions.connectHandlerOptions...)
-		h = newSignatureVerifierMiddleware(defaultOptions.verifySignatureFn, defaultOptions.verifySignatureMaxBodySize)(h)
-		return path, h
+
+		telemetry := newHandlerTelemetry(defaultOptions.telemetry)
+		connectHandlerOptions := defaultOptions.sdkConnectHandlerOptions(telemetry)
+
+		path, h := handler(p, connectHandlerOptions...)
+		h = newSignatureVerifierMiddleware(
+			defaultOptions.verifySignatureFn,
+			defaultOptions.verifySignatureMaxBodySize,
+			defaultOptions.maxBodySizeByProcedure(),
+		)(h)
+		if telemetry != nil {
+			h = telemetry.middleware()(h)
+		}
+		return path, &configuredHandler{Handler: h, config: defaultOptions.describeHandler(path)}
 	}
 }
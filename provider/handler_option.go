@@ -1,7 +1,12 @@
 package provider
 
 import (
+	"time"
+
 	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -9,26 +14,74 @@ const (
 )
 
 type providerHandlerOptions struct {
+	networkPublicKey           NetworkPublicKeyHexed
 	verifySignatureFn          VerifySignature
+	customVerifySignature      bool
 	verifySignatureMaxBodySize int64
 	connectHandlerOptions      []connect.HandlerOption
+	telemetry                  telemetryOptions
+	recoverPanics              bool
+	panicHook                  PanicHook
+	timeout                    time.Duration
+	deadlineCutoff             bool
+	concurrencyLimit           concurrencyLimitOptions
+	interceptors               []connect.Interceptor
+	procedures                 map[string]*procedureOptions
 }
 
 func newDefaultHandlerOptions(verifySignatureFn VerifySignature) (providerHandlerOptions, error) {
 	return providerHandlerOptions{
 		verifySignatureMaxBodySize: defaultMaxBodySize,
-		connectHandlerOptions: []connect.HandlerOption{
-			connect.WithInterceptors(signatureErrorInterceptor()),
-		},
-		verifySignatureFn: verifySignatureFn,
+		verifySignatureFn:          verifySignatureFn,
+		recoverPanics:              true,
+		panicHook:                  defaultPanicHook,
 	}, nil
 }
 
+// sdkConnectHandlerOptions returns the interceptors installed by the SDK,
+// followed by the user supplied connect handler options. Interceptors are
+// listed from the outermost to the innermost.
+func (h *providerHandlerOptions) sdkConnectHandlerOptions(telemetry *handlerTelemetry) []connect.HandlerOption {
+	var opts []connect.HandlerOption
+	if telemetry != nil {
+		// Outermost, so that signature errors and panics are recorded as well
+		opts = append(opts, connect.WithInterceptors(telemetry.interceptor()))
+	}
+	opts = append(opts, connect.WithInterceptors(
+		rpcObserverInterceptor(),
+		// Outside of the panic recovery, which must run in the handler goroutine
+		deadlineInterceptor(h.timeout, h.timeoutByProcedure(), h.deadlineCutoff),
+	))
+	if h.recoverPanics {
+		opts = append(opts, newRecoverHandlerOption(h.panicHook))
+	}
+	opts = append(opts, connect.WithInterceptors(
+		signatureErrorInterceptor(),
+		// Inside the deadline, so the slot is held until the handler really returns
+		concurrencyLimitInterceptor(h.concurrencyLimit, h.concurrencyLimitByProcedure()),
+	))
+	opts = append(opts, h.connectHandlerOptions...)
+	if len(h.interceptors) > 0 {
+		opts = append(opts, connect.WithInterceptors(handlerInterceptors{
+			interceptors: h.interceptors,
+			skipped:      h.skipHandlerInterceptorsByProcedure(),
+		}))
+	}
+
+	// Innermost, after the interceptors enabled for the whole handler
+	if interceptors := h.interceptorsByProcedure(); len(interceptors) > 0 {
+		opts = append(opts, connect.WithInterceptors(interceptors))
+	}
+
+	return opts
+}
+
 type HandlerOption func(*providerHandlerOptions)
 
 func WithVerifySignatureFn(fn VerifySignature) HandlerOption {
 	return func(h *providerHandlerOptions) {
 		h.verifySignatureFn = fn
+		h.customVerifySignature = true
 	}
 }
 
@@ -38,8 +91,18 @@ func WithConnectHandlerOptions(opts ...connect.HandlerOption) HandlerOption {
 	}
 }
 
+// WithHandlerInterceptors enables interceptors for every procedure of the
+// handler. Unlike interceptors passed with WithConnectHandlerOptions, they can
+// be disabled per procedure with WithoutHandlerInterceptors.
+func WithHandlerInterceptors(interceptors ...connect.Interceptor) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.interceptors = append(h.interceptors, interceptors...)
+	}
+}
+
 // WithMaxBodySize sets the maximum allowed request body size for signature verification.
 // If size is <= 0, the default size will be used.
+// It can be overridden per procedure with WithProcedureMaxBodySize.
 func WithMaxBodySize(size int64) HandlerOption {
 	return func(h *providerHandlerOptions) {
 		if size > 0 {
@@ -47,3 +110,72 @@ func WithMaxBodySize(size int64) HandlerOption {
 		}
 	}
 }
+
+// WithTracerProvider enables OpenTelemetry tracing of the handler. Every RPC
+// gets a server span, continuing the W3C trace context sent by the network,
+// with a child span for signature verification.
+func WithTracerProvider(provider trace.TracerProvider) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.telemetry.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider enables OpenTelemetry metrics of the handler: RPC and
+// signature verification durations, and signature verification failures by reason.
+func WithMeterProvider(provider metric.MeterProvider) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.telemetry.meterProvider = provider
+	}
+}
+
+// WithPropagator sets the propagator used to extract the trace context from
+// incoming requests. Defaults to W3C Trace Context.
+func WithPropagator(propagator propagation.TextMapPropagator) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.telemetry.propagator = propagator
+	}
+}
+
+// WithHandlerTimeout bounds the context passed to the provider service
+// handlers. A shorter timeout sent by the network is honoured, and so is the
+// write timeout of the server, less a tenth to write the response. Handlers
+// returning the context error once the deadline passed get a DeadlineExceeded
+// error, see WithDeadlineCutoff for handlers ignoring the context.
+// A timeout of 0 means no timeout, negative timeouts are ignored.
+// It can be overridden per procedure with WithProcedureTimeout.
+func WithHandlerTimeout(timeout time.Duration) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		if timeout >= 0 {
+			h.timeout = timeout
+		}
+	}
+}
+
+// WithDeadlineCutoff returns a DeadlineExceeded error to the network as soon
+// as the handler deadline passes, even if the handler is still running, which
+// is then left running in the background. Its result is discarded while its
+// side effects persist: the network may retry a payout which went through.
+// Only enable it for handlers which are safe to run twice, and which stop
+// once their context is done.
+func WithDeadlineCutoff() HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.deadlineCutoff = true
+	}
+}
+
+// WithPanicHook sets the hook called when a provider service handler panics,
+// e.g. to report the panic and its stack trace to an error tracker.
+// Defaults to logging with the default slog logger.
+func WithPanicHook(hook PanicHook) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.panicHook = hook
+	}
+}
+
+// WithoutPanicRecovery disables the recovery of panics in provider service
+// handlers, leaving them to net/http.
+func WithoutPanicRecovery() HandlerOption {
+	return func(h *providerHandlerOptions) {
+		h.recoverPanics = false
+	}
+}
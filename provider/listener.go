@@ -0,0 +1,402 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Address prefixes selecting the listener created by StartServer
+const (
+	UnixAddrPrefix    = "unix:"
+	SystemdAddrPrefix = "systemd:"
+)
+
+// listenFDsStart is the first file descriptor passed with LISTEN_FDS
+var listenFDsStart = 3
+
+// createListener returns the listener injected with WithListener, or creates
+// one for the configured address.
+func createListener(opts *serverOptions) (net.Listener, error) {
+	if opts.listener != nil {
+		return opts.listener, nil
+	}
+	return listen(opts.addr)
+}
+
+// listen creates a listener for a TCP address, or for an address with one of
+// the Unix or systemd prefixes.
+func listen(addr string) (net.Listener, error) {
+	var (
+		listener net.Listener
+		err      error
+	)
+	switch {
+	case strings.HasPrefix(addr, UnixAddrPrefix):
+		listener, err = net.Listen("unix", strings.TrimPrefix(addr, UnixAddrPrefix))
+	case strings.HasPrefix(addr, SystemdAddrPrefix):
+		listener, err = systemdListener(strings.TrimPrefix(addr, SystemdAddrPrefix))
+	default:
+		listener, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create listener on %s: %w", addr, err)
+	}
+	return listener, nil
+}
+
+// systemdListener returns the socket passed by systemd socket activation, as
+// described in sd_listen_fds(3). If name is empty the first socket is used,
+// otherwise the socket with the matching FileDescriptorName.
+func systemdListener(name string) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, ErrNoSystemdSocket
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return nil, ErrNoSystemdSocket
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	for i := range count {
+		if name != "" && (i >= len(names) || names[i] != name) {
+			continue
+		}
+
+		fd := listenFDsStart + i
+		file := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		// FileListener duplicates the descriptor, the original is not needed anymore
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("using systemd socket %d: %w", fd, err)
+		}
+		return listener, nil
+	}
+
+	return nil, ErrNoSystemdSocket
+}
+
+// maxProbeAccepted bounds the addresses of the connections accepted while a
+// readiness probe waits for its own.
+const maxProbeAccepted = 1024
+
+// probeDialTimeout bounds the dial checking whether the readiness probe can
+// reach the listener by its address.
+const probeDialTimeout = 250 * time.Millisecond
+
+// serverListener is the listener of StartServer. By default, Serve accepts
+// connections directly from the underlying listener. With the PROXY protocol,
+// or if the address of the listener cannot be dialed, connections are
+// accepted in the background instead, so they can be prepared concurrently,
+// like reading a PROXY header without blocking the accept loop, and so the
+// readiness check reaches the server without dialing.
+type serverListener struct {
+	net.Listener
+	onError func(error)
+
+	// prepare is run for every connection, at most cap(pending) at once, and
+	// probeHeader returns what the readiness probe sends first, if anything.
+	prepare     func(net.Conn) (net.Conn, error)
+	probeHeader func(localAddr net.Addr) []byte
+	pending     chan struct{}
+
+	// direct is set by start if Accept reads the underlying listener itself,
+	// and dial if the readiness probe can dial the listener.
+	direct bool
+	dial   bool
+
+	// ready holds the in-memory connection of the readiness check, once it
+	// can be returned by Accept.
+	ready     chan net.Conn
+	conns     chan net.Conn
+	errs      chan error
+	done      chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+
+	probing  atomic.Int32
+	probeMu  sync.Mutex
+	injected net.Conn                 // waiting for a connection to be accepted
+	accepted map[string]bool          // addresses accepted while probing
+	probes   map[string]chan struct{} // probes waiting for their connection
+}
+
+func newServerListener(listener net.Listener, onError func(error)) *serverListener {
+	return &serverListener{
+		Listener: listener,
+		onError:  onError,
+		ready:    make(chan net.Conn, 1),
+		conns:    make(chan net.Conn),
+		errs:     make(chan error),
+		done:     make(chan struct{}),
+		accepted: make(map[string]bool),
+		probes:   make(map[string]chan struct{}),
+	}
+}
+
+// start checks whether the readiness probe can dial the listener, before
+// Serve takes over, and accepts connections directly if nothing has to be
+// done before Serve gets them.
+func (l *serverListener) start() {
+	ctx, cancel := context.WithTimeout(context.Background(), probeDialTimeout)
+	defer cancel()
+
+	if conn, err := l.dialProbe(ctx); err == nil {
+		// Accepted and closed by Serve like any other connection
+		conn.Close()
+		l.dial = true
+	}
+	l.direct = l.dial && l.prepare == nil
+}
+
+func (l *serverListener) Accept() (net.Conn, error) {
+	if l.direct {
+		if len(l.ready) > 0 {
+			select {
+			case conn := <-l.ready:
+				return conn, nil
+			default:
+			}
+		}
+		conn, err := l.Listener.Accept()
+		if err == nil {
+			l.noteAccepted(conn.RemoteAddr())
+		}
+		return conn, err
+	}
+
+	l.startOnce.Do(func() { go l.acceptLoop() })
+
+	select {
+	case conn := <-l.ready:
+		return conn, nil
+	case conn := <-l.conns:
+		return conn, nil
+	case err := <-l.errs:
+		return nil, err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *serverListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return l.Listener.Close()
+}
+
+// inject makes Accept return conn, once the next connection is accepted from
+// the underlying listener if the probe dials it, which proves that Serve
+// still accepts connections, or right away otherwise.
+func (l *serverListener) inject(conn net.Conn) {
+	if l.dial {
+		l.probeMu.Lock()
+		stale := l.injected
+		l.injected = conn
+		l.probeMu.Unlock()
+		if stale != nil {
+			stale.Close()
+		}
+		return
+	}
+	l.setReady(conn)
+}
+
+// setReady makes conn the next connection returned by Accept, replacing the
+// one of a previous readiness check not accepted yet.
+func (l *serverListener) setReady(conn net.Conn) {
+	select {
+	case stale := <-l.ready:
+		stale.Close()
+	default:
+	}
+	l.ready <- conn
+}
+
+func (l *serverListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			// Serve decides whether to retry or to stop
+			select {
+			case l.errs <- err:
+			case <-l.done:
+				return
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		l.noteAccepted(conn.RemoteAddr())
+		if l.prepare == nil {
+			l.deliver(conn)
+			continue
+		}
+
+		select {
+		case l.pending <- struct{}{}:
+		default:
+			conn.Close()
+			l.reportError(fmt.Errorf("%w, closing connection from %s", ErrTooManyPendingConnections, conn.RemoteAddr()))
+			continue
+		}
+		go func() {
+			defer func() { <-l.pending }()
+
+			prepared, err := l.prepare(conn)
+			if err != nil {
+				conn.Close()
+				l.reportError(err)
+				return
+			}
+			l.deliver(prepared)
+		}()
+	}
+}
+
+func (l *serverListener) reportError(err error) {
+	if l.onError != nil {
+		l.onError(err)
+	}
+}
+
+// probe connects to the listener, and waits until the connection is returned
+// by the Accept of the underlying listener, which proves that it still
+// accepts connections. Listeners which cannot be dialed are not probed.
+func (l *serverListener) probe(ctx context.Context) error {
+	if !l.dial {
+		return nil
+	}
+
+	l.probing.Add(1)
+	defer func() {
+		l.probeMu.Lock()
+		defer l.probeMu.Unlock()
+		if l.probing.Add(-1) == 0 {
+			clear(l.accepted)
+		}
+	}()
+
+	conn, err := l.dialProbe(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	key := probeKey(conn.LocalAddr())
+	l.probeMu.Lock()
+	if l.accepted[key] {
+		delete(l.accepted, key)
+		l.probeMu.Unlock()
+		return nil
+	}
+	accepted := make(chan struct{})
+	l.probes[key] = accepted
+	l.probeMu.Unlock()
+
+	select {
+	case <-accepted:
+		return nil
+	case <-l.done:
+		return net.ErrClosed
+	case <-ctx.Done():
+		l.probeMu.Lock()
+		delete(l.probes, key)
+		l.probeMu.Unlock()
+		return fmt.Errorf("connection not accepted: %w", ctx.Err())
+	}
+}
+
+// dialProbe connects to the listener, and sends the probe header if any.
+func (l *serverListener) dialProbe(ctx context.Context) (net.Conn, error) {
+	addr := l.Addr()
+	switch addr.Network() {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, fmt.Errorf("cannot dial %s address %s", addr.Network(), addr)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, addr.Network(), dialAddress(addr))
+	if err != nil {
+		return nil, err
+	}
+	if l.probeHeader != nil {
+		if header := l.probeHeader(conn.LocalAddr()); header != nil {
+			if _, err := conn.Write(header); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+	}
+	return conn, nil
+}
+
+// noteAccepted hands the injected connection over to Accept, and wakes up
+// the probe waiting for the connection from addr, or records addr for a
+// probe which did not register its connection yet. It only costs an atomic
+// load outside of the readiness checks.
+func (l *serverListener) noteAccepted(addr net.Addr) {
+	if l.probing.Load() == 0 {
+		return
+	}
+
+	l.probeMu.Lock()
+	defer l.probeMu.Unlock()
+
+	if l.injected != nil {
+		l.setReady(l.injected)
+		l.injected = nil
+	}
+	key := probeKey(addr)
+	if accepted, ok := l.probes[key]; ok {
+		close(accepted)
+		delete(l.probes, key)
+		return
+	}
+	if len(l.accepted) < maxProbeAccepted {
+		l.accepted[key] = true
+	}
+}
+
+// probeKey identifies the connection of a probe by its address. Connections
+// over Unix sockets have no address, any of them matches.
+func probeKey(addr net.Addr) string {
+	if _, ok := addr.(*net.TCPAddr); !ok {
+		return ""
+	}
+	return addr.String()
+}
+
+// dialAddress returns the address to dial to reach a listener, replacing the
+// unspecified IP of listeners bound to all interfaces with loopback.
+func dialAddress(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || !tcpAddr.IP.IsUnspecified() {
+		return addr.String()
+	}
+
+	loopback := net.IPv4(127, 0, 0, 1)
+	if tcpAddr.IP.To4() == nil {
+		loopback = net.IPv6loopback
+	}
+	return (&net.TCPAddr{IP: loopback, Port: tcpAddr.Port}).String()
+}
+
+func (l *serverListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		conn.Close()
+	}
+}
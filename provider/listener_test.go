@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func shutdownOnCleanup(t *testing.T, srv *RunningServer) {
+	t.Helper()
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+}
+
+// startServerT starts a server shut down with the test, on a loopback port
+// picked by the system unless the options set another address.
+func startServerT(t *testing.T, handler http.Handler, opts ...ServerOption) *RunningServer {
+	t.Helper()
+
+	srv, err := StartServer(handler, append([]ServerOption{WithAddr("127.0.0.1:0")}, opts...)...)
+	require.NoError(t, err)
+	shutdownOnCleanup(t, srv)
+	return srv
+}
+
+// getStatus sends a GET request to the server through the given dial function.
+func getStatus(t *testing.T, dial func(ctx context.Context) (net.Conn, error)) int {
+	t.Helper()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dial(ctx)
+		},
+	}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("http://provider/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func dialAddr(addr net.Addr) func(ctx context.Context) (net.Conn, error) {
+	return func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, addr.Network(), addr.String())
+	}
+}
+
+func TestStartServer_ReportsBoundAddr(t *testing.T) {
+	srv := startServerT(t, okHandler)
+
+	addr, ok := srv.Addr().(*net.TCPAddr)
+	require.True(t, ok)
+	assert.NotZero(t, addr.Port)
+	assert.Equal(t, http.StatusOK, getStatus(t, dialAddr(srv.Addr())))
+}
+
+func TestStartServer_WithListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv, err := StartServer(okHandler, WithListener(listener), WithAddr("invalid:address"))
+	require.NoError(t, err)
+
+	assert.Equal(t, listener.Addr(), srv.Addr())
+	assert.Equal(t, http.StatusOK, getStatus(t, dialAddr(srv.Addr())))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err, "the listener must be closed on shutdown")
+}
+
+// memoryListener is an in-memory listener, whose address cannot be dialed.
+type memoryListener struct {
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newMemoryListener() *memoryListener {
+	return &memoryListener{conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *memoryListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *memoryListener) Close() error {
+	l.closeOnce.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *memoryListener) Addr() net.Addr {
+	return memoryAddr{}
+}
+
+func (l *memoryListener) dial(ctx context.Context) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type memoryAddr struct{}
+
+func (memoryAddr) Network() string { return "memory" }
+func (memoryAddr) String() string  { return "provider" }
+
+func TestStartServer_ListenerNotDialable(t *testing.T) {
+	listener := newMemoryListener()
+
+	srv := startServerT(t, okHandler, WithListener(listener))
+	assert.False(t, srv.listener.(*serverListener).dial)
+
+	assert.Equal(t, http.StatusOK, getStatus(t, listener.dial))
+}
+
+func TestStartServer_AcceptsDirectly(t *testing.T) {
+	srv := startServerT(t, okHandler)
+	assert.True(t, srv.listener.(*serverListener).direct)
+
+	// Reading the PROXY header takes the accept loop
+	srv = startServerT(t, okHandler, WithProxyProtocol())
+	assert.False(t, srv.listener.(*serverListener).direct)
+}
+
+func TestStartServer_UnixSocket(t *testing.T) {
+	// Unix socket paths are limited to about 100 bytes, shorter than some temp dirs
+	dir, err := os.MkdirTemp("", "provider")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "provider.sock")
+
+	srv := startServerT(t, okHandler, WithAddr(UnixAddrPrefix+path))
+
+	assert.Equal(t, "unix", srv.Addr().Network())
+	assert.Equal(t, path, srv.Addr().String())
+	assert.Equal(t, http.StatusOK, getStatus(t, dialAddr(srv.Addr())))
+}
+
+func TestStartServer_NoSystemdSocket(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+
+	srv, err := StartServer(okHandler, WithAddr(SystemdAddrPrefix))
+	assert.ErrorIs(t, err, ErrNoSystemdSocket)
+	assert.Nil(t, srv)
+}
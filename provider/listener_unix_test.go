@@ -0,0 +1,67 @@
+//go:build unix
+
+package provider
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// passSystemdSocket sets up the environment of systemd socket activation with
+// a copy of the listener as the last of the named sockets, and returns its
+// descriptor. The other sockets are never opened, as they are not selected.
+func passSystemdSocket(t *testing.T, listener *net.TCPListener, names ...string) int {
+	t.Helper()
+
+	file, err := listener.File()
+	require.NoError(t, err)
+	defer file.Close()
+
+	fd, err := syscall.Dup(int(file.Fd()))
+	require.NoError(t, err)
+
+	previousStart := listenFDsStart
+	listenFDsStart = fd - len(names) + 1
+	t.Cleanup(func() { listenFDsStart = previousStart })
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", strconv.Itoa(len(names)))
+	t.Setenv("LISTEN_FDNAMES", strings.Join(names, ":"))
+	return fd
+}
+
+func newTCPListener(t *testing.T) *net.TCPListener {
+	t.Helper()
+
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	return listener
+}
+
+func TestStartServer_SystemdSocketActivation(t *testing.T) {
+	listener := newTCPListener(t)
+	passSystemdSocket(t, listener, "metrics", "provider")
+
+	srv := startServerT(t, okHandler, WithAddr(SystemdAddrPrefix+"provider"))
+
+	assert.Equal(t, listener.Addr().String(), srv.Addr().String())
+	assert.Equal(t, http.StatusOK, getStatus(t, dialAddr(srv.Addr())))
+}
+
+func TestStartServer_SystemdSocketNotFound(t *testing.T) {
+	fd := passSystemdSocket(t, newTCPListener(t), "metrics")
+	t.Cleanup(func() { syscall.Close(fd) })
+
+	srv, err := StartServer(okHandler, WithAddr(SystemdAddrPrefix+"provider"))
+	assert.ErrorIs(t, err, ErrNoSystemdSocket)
+	assert.Nil(t, srv)
+}
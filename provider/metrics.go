@@ -0,0 +1,196 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/t-0-network/provider-sdk-go/internal/metrics"
+)
+
+const (
+	// DefaultMetricsPath is the path on which Prometheus metrics are served
+	// by the admin listener.
+	DefaultMetricsPath = "/metrics"
+
+	// unknownProcedure labels requests which never reached a provider
+	// service handler, to keep the label cardinality bounded.
+	unknownProcedure = "unknown"
+)
+
+type metricsOptions struct {
+	addr     string
+	registry *prometheus.Registry
+}
+
+// WithMetrics enables Prometheus metrics of the provider server. The metrics
+// are served in the Prometheus text format on DefaultMetricsPath of a separate
+// admin listener bound to addr, which is started by StartServer alongside the
+// server itself. NewServer only instruments the handler, serving the registry
+// is then up to the caller.
+//
+// If registry is nil, a new registry with Go runtime and process collectors is
+// used. Pass the same registry to network.WithPrometheusRegisterer to expose
+// outbound network client call stats on the same endpoint.
+func WithMetrics(addr string, registry *prometheus.Registry) ServerOption {
+	return func(opts *serverOptions) {
+		if registry == nil {
+			registry = prometheus.NewRegistry()
+			registry.MustRegister(
+				collectors.NewGoCollector(),
+				collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			)
+		}
+		opts.metrics = &metricsOptions{
+			addr:     addr,
+			registry: registry,
+		}
+	}
+}
+
+// serverMetrics holds the Prometheus collectors of a provider server.
+type serverMetrics struct {
+	requests             *prometheus.CounterVec
+	requestDuration      *prometheus.HistogramVec
+	requestsInFlight     prometheus.Gauge
+	signatureErrors      *prometheus.CounterVec
+	bodySizeRejections   *prometheus.CounterVec
+	signatureVerifyTimes *prometheus.HistogramVec
+}
+
+// newServerMetrics registers the server collectors, reusing the ones already
+// registered by another server sharing the same registry.
+func newServerMetrics(registerer prometheus.Registerer) (*serverMetrics, error) {
+	m := &serverMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tzero_provider_requests_total",
+			Help: "Number of requests handled by the provider server, by procedure and Connect code.",
+		}, []string{"procedure", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tzero_provider_request_duration_seconds",
+			Help:    "Latency of requests handled by the provider server, by procedure.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"procedure"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tzero_provider_requests_in_flight",
+			Help: "Number of requests currently being handled by the provider server.",
+		}),
+		signatureErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tzero_provider_signature_errors_total",
+			Help: "Number of requests which failed signature verification, by procedure and reason.",
+		}, []string{"procedure", "reason"}),
+		bodySizeRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tzero_provider_body_size_rejections_total",
+			Help: "Number of requests rejected for exceeding the maximum body size, by procedure.",
+		}, []string{"procedure"}),
+		signatureVerifyTimes: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tzero_provider_signature_verification_duration_seconds",
+			Help:    "Latency of request signature verification, by procedure.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"procedure"}),
+	}
+
+	var err error
+	if m.requests, err = metrics.RegisterOrReuse(registerer, m.requests); err != nil {
+		return nil, err
+	}
+	if m.requestDuration, err = metrics.RegisterOrReuse(registerer, m.requestDuration); err != nil {
+		return nil, err
+	}
+	if m.requestsInFlight, err = metrics.RegisterOrReuse(registerer, m.requestsInFlight); err != nil {
+		return nil, err
+	}
+	if m.signatureErrors, err = metrics.RegisterOrReuse(registerer, m.signatureErrors); err != nil {
+		return nil, err
+	}
+	if m.bodySizeRejections, err = metrics.RegisterOrReuse(registerer, m.bodySizeRejections); err != nil {
+		return nil, err
+	}
+	if m.signatureVerifyTimes, err = metrics.RegisterOrReuse(registerer, m.signatureVerifyTimes); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// middleware records the request metrics. The procedure label is only set for
+// requests which reached a provider service handler.
+func (m *serverMetrics) middleware() middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			m.requestsInFlight.Inc()
+			defer m.requestsInFlight.Dec()
+
+			startedAt := time.Now()
+			observation := &requestObservation{}
+			next.ServeHTTP(w, r.WithContext(withRequestObserver(r.Context(), observation)))
+
+			observation.mu.Lock()
+			defer observation.mu.Unlock()
+
+			procedure, code := unknownProcedure, connect.CodeUnknown.String()
+			if observation.procedure != "" {
+				procedure, code = observation.procedure, observation.code
+			}
+
+			m.requests.WithLabelValues(procedure, code).Inc()
+			m.requestDuration.WithLabelValues(procedure).Observe(time.Since(startedAt).Seconds())
+
+			if observation.signatureVerified {
+				m.signatureVerifyTimes.WithLabelValues(procedure).Observe(observation.signatureElapsed.Seconds())
+			}
+			if observation.signatureErr != nil {
+				m.signatureErrors.WithLabelValues(procedure, string(observation.signatureErr.Reason)).Inc()
+				if observation.signatureErr.Reason == SignatureErrorReasonBodyTooLarge {
+					m.bodySizeRejections.WithLabelValues(procedure).Inc()
+				}
+			}
+		})
+	}
+}
+
+// requestObservation collects what happened to a single request on its way
+// through the provider handler.
+type requestObservation struct {
+	mu                sync.Mutex
+	procedure         string
+	code              string
+	signatureVerified bool
+	signatureElapsed  time.Duration
+	signatureErr      *SignatureError
+}
+
+func (o *requestObservation) observeSignatureVerification(
+	_ context.Context, _ string, sigErr *SignatureError, elapsed time.Duration,
+) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.signatureVerified = true
+	o.signatureElapsed = elapsed
+	o.signatureErr = sigErr
+}
+
+func (o *requestObservation) observeRPC(_ context.Context, procedure string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.procedure = procedure
+	o.code = "ok"
+	if err != nil {
+		o.code = connect.CodeOf(err).String()
+	}
+}
+
+// startMetricsServer serves the metrics registry on the admin address.
+func startMetricsServer(opts *metricsOptions, readHeaderTimeout time.Duration, group *serveGroup) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle(DefaultMetricsPath, promhttp.HandlerFor(opts.registry, promhttp.HandlerOpts{}))
+
+	return startAdminServer(opts.addr, mux, readHeaderTimeout, group)
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+func TestWithMetrics_ExposesPrometheusMetrics(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(updateLimitProvider{}),
+			WithMaxBodySize(16),
+		),
+	)
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	srv, err := StartServer(handler, WithAddr("127.0.0.1:0"), WithMetrics("127.0.0.1:0", registry))
+	require.NoError(t, err)
+	addr, metricsAddr := srv.Addr().String(), adminAddr(srv)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		assert.NoError(t, srv.Shutdown(ctx))
+	}()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL("http://"+addr),
+		network.WithPrometheusRegisterer(registry),
+	)
+	require.NoError(t, err)
+
+	_, err = client.UpdateLimit(context.Background(), connect.NewRequest(&payment.UpdateLimitRequest{}))
+	require.NoError(t, err)
+
+	// Exceeds the 16 bytes body cap
+	_, err = client.UpdateLimit(context.Background(), connect.NewRequest(&payment.UpdateLimitRequest{
+		Limits: []*payment.UpdateLimitRequest_Limit{{Version: 1000, CounterpartId: 1000}, {Version: 2000, CounterpartId: 2000}, {Version: 3000, CounterpartId: 3000}},
+	}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	// Never reaches a provider service handler
+	res, err := http.Post("http://"+addr+"/unknown", "application/json", bytes.NewReader(nil))
+	require.NoError(t, err)
+	res.Body.Close()
+
+	res, err = http.Get("http://" + metricsAddr + DefaultMetricsPath)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	metrics := string(body)
+
+	procedure := paymentconnect.ProviderServiceUpdateLimitProcedure
+	for _, expected := range []string{
+		fmt.Sprintf(`tzero_provider_requests_total{code="ok",procedure=%q} 1`, procedure),
+		fmt.Sprintf(`tzero_provider_requests_total{code="invalid_argument",procedure=%q} 1`, procedure),
+		`tzero_provider_requests_total{code="unknown",procedure="unknown"} 1`,
+		fmt.Sprintf(`tzero_provider_signature_errors_total{procedure=%q,reason="body_too_large"} 1`, procedure),
+		fmt.Sprintf(`tzero_provider_body_size_rejections_total{procedure=%q} 1`, procedure),
+		fmt.Sprintf(`tzero_provider_request_duration_seconds_count{procedure=%q} 2`, procedure),
+		`tzero_provider_requests_in_flight 0`,
+		fmt.Sprintf(`tzero_network_client_requests_total{code="ok",procedure=%q} 1`, procedure),
+		fmt.Sprintf(`tzero_network_client_requests_total{code="invalid_argument",procedure=%q} 1`, procedure),
+	} {
+		assert.True(t, strings.Contains(metrics, expected), "missing metric line: %s", expected)
+	}
+}
+
+func TestWithMetrics_SharedRegistry(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	registry := prometheus.NewRegistry()
+
+	assert.NotPanics(t, func() {
+		NewServer(handler, WithMetrics(":0", registry))
+		NewServer(handler, WithMetrics(":0", registry))
+	})
+}
+
+func TestWithMetrics_ConflictingCollector(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tzero_provider_requests_in_flight",
+		Help: "Registered by the application.",
+	}))
+
+	srv, err := StartServer(okHandler, WithAddr("127.0.0.1:0"), WithMetrics("127.0.0.1:0", registry))
+	assert.ErrorContains(t, err, "registering provider server metrics")
+	assert.Nil(t, srv)
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// signatureObserver is notified about the outcome of every signature
+// verification performed by the signature verifier middleware.
+// A nil sigErr means the signature was verified successfully.
+type signatureObserver interface {
+	observeSignatureVerification(ctx context.Context, procedure string, sigErr *SignatureError, elapsed time.Duration)
+}
+
+// rpcObserver is notified about the outcome of every RPC which reached a
+// provider service handler.
+type rpcObserver interface {
+	observeRPC(ctx context.Context, procedure string, err error)
+}
+
+type requestObserversContextKey struct{}
+
+// withRequestObserver returns a copy of ctx carrying the given observer in
+// addition to the ones already registered by outer middlewares. The observer
+// should implement signatureObserver, rpcObserver or both.
+func withRequestObserver(ctx context.Context, observer any) context.Context {
+	observers, _ := ctx.Value(requestObserversContextKey{}).([]any)
+	observers = append(observers[:len(observers):len(observers)], observer)
+	return context.WithValue(ctx, requestObserversContextKey{}, observers)
+}
+
+func notifySignatureObservers(ctx context.Context, procedure string, sigErr *SignatureError, elapsed time.Duration) {
+	observers, _ := ctx.Value(requestObserversContextKey{}).([]any)
+	for _, o := range observers {
+		if so, ok := o.(signatureObserver); ok {
+			so.observeSignatureVerification(ctx, procedure, sigErr, elapsed)
+		}
+	}
+}
+
+func notifyRPCObservers(ctx context.Context, procedure string, err error) {
+	observers, _ := ctx.Value(requestObserversContextKey{}).([]any)
+	for _, o := range observers {
+		if ro, ok := o.(rpcObserver); ok {
+			ro.observeRPC(ctx, procedure, err)
+		}
+	}
+}
+
+// rpcObserverInterceptor reports the outcome of every RPC to the observers
+// registered in the request context.
+func rpcObserverInterceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			res, err := next(ctx, req)
+			notifyRPCObservers(ctx, req.Spec().Procedure, err)
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// procedureOptions holds the overrides of the handler options for a single
+// procedure. Zero values and nil pointers mean no override.
+type procedureOptions struct {
+	maxBodySize  int64
+	timeout      *time.Duration
+	interceptors []connect.Interceptor
+
+	concurrencyLimit        *concurrencyLimitOptions
+	skipHandlerInterceptors bool
+}
+
+// ProcedureOption overrides a handler option for a single procedure.
+type ProcedureOption func(*procedureOptions)
+
+// WithProcedureOptions overrides handler options for a single procedure,
+// identified by its generated constant, e.g.
+// paymentconnect.ProviderServiceAppendLedgerEntriesProcedure.
+//
+// Example:
+//
+//	provider.Handler(paymentconnect.NewProviderServiceHandler, handler,
+//	    provider.WithMaxBodySize(64*1024),
+//	    provider.WithProcedureOptions(paymentconnect.ProviderServiceAppendLedgerEntriesProcedure,
+//	        provider.WithProcedureMaxBodySize(16*1024*1024),
+//	        provider.WithProcedureTimeout(30*time.Second),
+//	    ),
+//	)
+func WithProcedureOptions(procedure string, opts ...ProcedureOption) HandlerOption {
+	return func(h *providerHandlerOptions) {
+		if h.procedures == nil {
+			h.procedures = make(map[string]*procedureOptions)
+		}
+		p, ok := h.procedures[procedure]
+		if !ok {
+			p = &procedureOptions{}
+			h.procedures[procedure] = p
+		}
+		for _, o := range opts {
+			o(p)
+		}
+	}
+}
+
+// WithProcedureMaxBodySize overrides the maximum allowed request body size for
+// signature verification. If size is <= 0, the handler-wide size will be used.
+func WithProcedureMaxBodySize(size int64) ProcedureOption {
+	return func(p *procedureOptions) {
+		if size > 0 {
+			p.maxBodySize = size
+		}
+	}
+}
+
+// WithProcedureTimeout overrides the handler timeout.
+// If the timeout is <= 0, the handler-wide timeout will be used.
+func WithProcedureTimeout(timeout time.Duration) ProcedureOption {
+	return func(p *procedureOptions) {
+		if timeout > 0 {
+			p.timeout = &timeout
+		}
+	}
+}
+
+// WithoutProcedureTimeout disables the handler timeout for the procedure. The
+// timeout sent by the network and the write timeout of the server still apply.
+func WithoutProcedureTimeout() ProcedureOption {
+	return func(p *procedureOptions) {
+		var none time.Duration
+		p.timeout = &none
+	}
+}
+
+// WithProcedureInterceptors enables interceptors for the procedure only. They
+// run after the interceptors enabled for the whole handler.
+func WithProcedureInterceptors(interceptors ...connect.Interceptor) ProcedureOption {
+	return func(p *procedureOptions) {
+		p.interceptors = append(p.interceptors, interceptors...)
+	}
+}
+
+// WithoutHandlerInterceptors disables the interceptors of WithHandlerInterceptors
+// for the procedure. Interceptors passed with WithConnectHandlerOptions always
+// apply.
+func WithoutHandlerInterceptors() ProcedureOption {
+	return func(p *procedureOptions) {
+		p.skipHandlerInterceptors = true
+	}
+}
+
+func (h *providerHandlerOptions) maxBodySizeByProcedure() map[string]int64 {
+	sizes := make(map[string]int64)
+	for procedure, p := range h.procedures {
+		if p.maxBodySize > 0 {
+			sizes[procedure] = p.maxBodySize
+		}
+	}
+	return sizes
+}
+
+func (h *providerHandlerOptions) timeoutByProcedure() map[string]time.Duration {
+	timeouts := make(map[string]time.Duration)
+	for procedure, p := range h.procedures {
+		if p.timeout != nil {
+			timeouts[procedure] = *p.timeout
+		}
+	}
+	return timeouts
+}
+
+func (h *providerHandlerOptions) interceptorsByProcedure() procedureInterceptors {
+	interceptors := make(procedureInterceptors)
+	for procedure, p := range h.procedures {
+		if len(p.interceptors) > 0 {
+			interceptors[procedure] = p.interceptors
+		}
+	}
+	return interceptors
+}
+
+func (h *providerHandlerOptions) skipHandlerInterceptorsByProcedure() map[string]bool {
+	skipped := make(map[string]bool)
+	for procedure, p := range h.procedures {
+		if p.skipHandlerInterceptors {
+			skipped[procedure] = true
+		}
+	}
+	return skipped
+}
+
+// handlerInterceptors is a connect.Interceptor running the interceptors
+// enabled for the whole handler, except for the skipped procedures.
+type handlerInterceptors struct {
+	interceptors []connect.Interceptor
+	skipped      map[string]bool
+}
+
+func (h handlerInterceptors) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	wrapped := next
+	for i := len(h.interceptors) - 1; i >= 0; i-- {
+		wrapped = h.interceptors[i].WrapUnary(wrapped)
+	}
+
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if h.skipped[req.Spec().Procedure] {
+			return next(ctx, req)
+		}
+		return wrapped(ctx, req)
+	}
+}
+
+func (h handlerInterceptors) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (h handlerInterceptors) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	wrapped := next
+	for i := len(h.interceptors) - 1; i >= 0; i-- {
+		wrapped = h.interceptors[i].WrapStreamingHandler(wrapped)
+	}
+
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if h.skipped[conn.Spec().Procedure] {
+			return next(ctx, conn)
+		}
+		return wrapped(ctx, conn)
+	}
+}
+
+// procedureInterceptors is a connect.Interceptor running the interceptors
+// enabled for the procedure of the request.
+type procedureInterceptors map[string][]connect.Interceptor
+
+func (p procedureInterceptors) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	wrapped := make(map[string]connect.UnaryFunc, len(p))
+	for procedure, interceptors := range p {
+		f := next
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			f = interceptors[i].WrapUnary(f)
+		}
+		wrapped[procedure] = f
+	}
+
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if f, ok := wrapped[req.Spec().Procedure]; ok {
+			return f(ctx, req)
+		}
+		return next(ctx, req)
+	}
+}
+
+func (p procedureInterceptors) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (p procedureInterceptors) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
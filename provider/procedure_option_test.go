@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+// deadlineRecordingProvider records the deadline of the handler contexts.
+type deadlineRecordingProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+	deadlines map[string]time.Duration
+}
+
+func (p *deadlineRecordingProvider) record(ctx context.Context, procedure string) {
+	if deadline, ok := ctx.Deadline(); ok {
+		p.deadlines[procedure] = time.Until(deadline)
+	}
+}
+
+func (p *deadlineRecordingProvider) PayOut(
+	ctx context.Context, _ *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	p.record(ctx, paymentconnect.ProviderServicePayOutProcedure)
+	return connect.NewResponse(&payment.PayoutResponse{}), nil
+}
+
+func (p *deadlineRecordingProvider) AppendLedgerEntries(
+	ctx context.Context, _ *connect.Request[payment.AppendLedgerEntriesRequest],
+) (*connect.Response[payment.AppendLedgerEntriesResponse], error) {
+	p.record(ctx, paymentconnect.ProviderServiceAppendLedgerEntriesProcedure)
+	return connect.NewResponse(&payment.AppendLedgerEntriesResponse{}), nil
+}
+
+func TestWithProcedureOptions(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+
+	intercepted := map[string]int{}
+	countingInterceptor := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			intercepted[req.Spec().Procedure]++
+			return next(ctx, req)
+		}
+	})
+
+	svc := &deadlineRecordingProvider{deadlines: map[string]time.Duration{}}
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc),
+			WithMaxBodySize(16),
+			WithHandlerTimeout(5*time.Second),
+			WithProcedureOptions(paymentconnect.ProviderServiceAppendLedgerEntriesProcedure,
+				WithProcedureMaxBodySize(1024*1024),
+				WithProcedureTimeout(time.Minute),
+				WithProcedureInterceptors(countingInterceptor),
+			),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+
+	entries := make([]*payment.AppendLedgerEntriesRequest_Transaction, 10)
+	for i := range entries {
+		entries[i] = &payment.AppendLedgerEntriesRequest_Transaction{TransactionId: uint64(i + 1000)}
+	}
+
+	_, err = client.AppendLedgerEntries(context.Background(), connect.NewRequest(&payment.AppendLedgerEntriesRequest{
+		Transactions: entries,
+	}))
+	require.NoError(t, err, "ledger batch is within the procedure body cap")
+
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{
+		PaymentId: 1000, PayoutId: 1000, ClientQuoteId: "client-quote-id",
+	}))
+	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), "pay out exceeds the handler body cap")
+
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	require.NoError(t, err)
+
+	assert.Greater(t, svc.deadlines[paymentconnect.ProviderServiceAppendLedgerEntriesProcedure], 5*time.Second)
+	assert.LessOrEqual(t, svc.deadlines[paymentconnect.ProviderServicePayOutProcedure], 5*time.Second)
+
+	assert.Equal(t, map[string]int{paymentconnect.ProviderServiceAppendLedgerEntriesProcedure: 1}, intercepted)
+}
+
+func TestWithProcedureOptions_InvalidValuesAreIgnored(t *testing.T) {
+	opts, err := newDefaultHandlerOptions(nil)
+	require.NoError(t, err)
+
+	WithProcedureOptions(paymentconnect.ProviderServicePayOutProcedure,
+		WithProcedureMaxBodySize(0),
+		WithProcedureTimeout(-time.Second),
+	)(&opts)
+
+	assert.Empty(t, opts.maxBodySizeByProcedure())
+	assert.Empty(t, opts.timeoutByProcedure())
+	assert.Empty(t, opts.interceptorsByProcedure())
+}
+
+func TestWithProcedureOptions_DisablesHandlerOptions(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+
+	intercepted := map[string]int{}
+	countingInterceptor := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			intercepted[req.Spec().Procedure]++
+			return next(ctx, req)
+		}
+	})
+
+	svc := &deadlineRecordingProvider{deadlines: map[string]time.Duration{}}
+	opts := []HandlerOption{
+		WithHandlerTimeout(5 * time.Second),
+		WithConcurrencyLimit(10, time.Second),
+		WithHandlerInterceptors(countingInterceptor),
+		WithProcedureOptions(paymentconnect.ProviderServiceAppendLedgerEntriesProcedure,
+			WithoutProcedureTimeout(),
+			WithoutProcedureConcurrencyLimit(),
+			WithoutHandlerInterceptors(),
+		),
+	}
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(svc), opts...),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = client.AppendLedgerEntries(context.Background(), connect.NewRequest(&payment.AppendLedgerEntriesRequest{}))
+	require.NoError(t, err)
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	require.NoError(t, err)
+
+	assert.NotContains(t, svc.deadlines, paymentconnect.ProviderServiceAppendLedgerEntriesProcedure)
+	assert.Contains(t, svc.deadlines, paymentconnect.ProviderServicePayOutProcedure)
+	assert.Equal(t, map[string]int{paymentconnect.ProviderServicePayOutProcedure: 1}, intercepted)
+
+	handlerOpts, err := newDefaultHandlerOptions(nil)
+	require.NoError(t, err)
+	for _, o := range opts {
+		o(&handlerOpts)
+	}
+	assert.Equal(t, map[string]concurrencyLimitOptions{
+		paymentconnect.ProviderServiceAppendLedgerEntriesProcedure: {},
+	}, handlerOpts.concurrencyLimitByProcedure(), "a zero limit disables the limiter")
+	config := handlerOpts.describeHandler("/" + paymentconnect.ProviderServiceName + "/")
+	assert.Contains(t, config.Procedures, procedureConfig{
+		Procedure:   paymentconnect.ProviderServiceAppendLedgerEntriesProcedure,
+		MaxBodySize: defaultMaxBodySize,
+	})
+	assert.Contains(t, config.Procedures, procedureConfig{
+		Procedure:        paymentconnect.ProviderServicePayOutProcedure,
+		MaxBodySize:      defaultMaxBodySize,
+		Timeout:          "5s",
+		ConcurrencyLimit: &concurrencyLimitConfig{MaxInFlight: 10, QueueTimeout: "1s"},
+		Interceptors:     1,
+	})
+}
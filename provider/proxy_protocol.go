@@ -0,0 +1,249 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits and signatures of the PROXY protocol, see
+// https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt
+const (
+	proxyV1Prefix    = "PROXY "
+	proxyV1MaxLength = 107
+	proxyV2Signature = "\r\n\r\n\x00\r\nQUIT\n"
+
+	proxyV2HeaderLength = 16
+	proxyV2CommandLocal = 0x0
+	proxyV2CommandProxy = 0x1
+	proxyV2FamilyTCP4   = 0x11
+	proxyV2FamilyTCP6   = 0x21
+)
+
+// defaultProxyHeaderTimeout bounds the PROXY header read if the server has
+// no read header timeout.
+const defaultProxyHeaderTimeout = 10 * time.Second
+
+// defaultMaxPendingProxyHeaders bounds the connections whose PROXY header is
+// being read, if the server has no WithMaxConnections limit.
+const defaultMaxPendingProxyHeaders = 1024
+
+type proxyProtocolOptions struct {
+	trustedProxies []netip.Prefix
+}
+
+// WithProxyProtocol reads a PROXY protocol v1 or v2 header at the start of
+// every connection, as sent by load balancers such as HAProxy or AWS NLB, and
+// uses the client address it carries as the remote address of the
+// connection, for WithAllowedCIDRs, the per-IP limit and the handlers.
+//
+// Only connections from the trusted proxies are expected to send a header,
+// others are served with their own address. Without trusted proxies, every
+// connection must send a header. Connections sending a LOCAL header, like
+// load balancer health checks, keep the proxy address.
+//
+// At most WithMaxConnections connections, or 1024 without limit, may be
+// sending their header at once, further connections are closed.
+//
+// The listener is wrapped by StartServer. BuildServer fails with this option,
+// wrap the listener served by the server with NewProxyProtocolListener
+// instead.
+func WithProxyProtocol(trustedProxies ...netip.Prefix) ServerOption {
+	return func(opts *serverOptions) {
+		opts.proxyProtocol = &proxyProtocolOptions{trustedProxies: trustedProxies}
+	}
+}
+
+// NewProxyProtocolListener wraps a listener to read the PROXY header of its
+// connections, as WithProxyProtocol does for StartServer, for servers created
+// by NewServer. The header read is bounded by headerTimeout, or by 10 seconds
+// if it is <= 0, and at most 1024 connections may be sending their header at
+// once. Rejected connections are logged with slog.
+//
+// Example:
+//
+//	server := provider.NewServer(handler, provider.WithMaxConnectionsPerIP(10))
+//	err := server.Serve(provider.NewProxyProtocolListener(listener, 0, trustedProxies...))
+func NewProxyProtocolListener(listener net.Listener, headerTimeout time.Duration, trustedProxies ...netip.Prefix) net.Listener {
+	wrapped := newServerListener(listener, logRejectedConn)
+	(&proxyProtocolOptions{trustedProxies: trustedProxies}).wrap(wrapped, headerTimeout, 0)
+	return wrapped
+}
+
+// logRejectedConn logs the connections rejected before reaching the server.
+func logRejectedConn(err error) {
+	slog.Warn("rejected provider server connection", "error", err)
+}
+
+// wrap makes the listener read the PROXY header of its connections, at most
+// maxPending at once, or defaultMaxPendingProxyHeaders if it is <= 0. The
+// readiness probe sends a LOCAL header when a header is expected from it.
+func (p *proxyProtocolOptions) wrap(listener *serverListener, headerTimeout time.Duration, maxPending int) {
+	if maxPending <= 0 {
+		maxPending = defaultMaxPendingProxyHeaders
+	}
+	listener.prepare = p.prepare(headerTimeout)
+	listener.pending = make(chan struct{}, maxPending)
+	listener.probeHeader = func(localAddr net.Addr) []byte {
+		if !p.trusted(localAddr) {
+			return nil
+		}
+		header := []byte(proxyV2Signature)
+		return append(header, 0x20|proxyV2CommandLocal, 0, 0, 0)
+	}
+}
+
+// proxyConn is a connection whose remote address was read from a PROXY header.
+type proxyConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// prepare reads the PROXY header of connections from trusted proxies, within
+// the header timeout.
+func (p *proxyProtocolOptions) prepare(headerTimeout time.Duration) func(net.Conn) (net.Conn, error) {
+	if headerTimeout <= 0 {
+		headerTimeout = defaultProxyHeaderTimeout
+	}
+
+	return func(conn net.Conn) (net.Conn, error) {
+		if !p.trusted(conn.RemoteAddr()) {
+			return conn, nil
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(headerTimeout)); err != nil {
+			return nil, err
+		}
+
+		reader := bufio.NewReader(conn)
+		remoteAddr, err := readProxyHeader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("reading PROXY header from %s: %w", conn.RemoteAddr(), err)
+		}
+		if remoteAddr == nil {
+			remoteAddr = conn.RemoteAddr()
+		}
+
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil, err
+		}
+		return &proxyConn{Conn: conn, reader: reader, remoteAddr: remoteAddr}, nil
+	}
+}
+
+func (p *proxyProtocolOptions) trusted(addr net.Addr) bool {
+	if len(p.trustedProxies) == 0 {
+		return true
+	}
+	ip, ok := remoteIP(addr)
+	return ok && containsIP(p.trustedProxies, ip)
+}
+
+// readProxyHeader reads a v1 or v2 header, and returns the client address, or
+// nil if the header does not carry one.
+func readProxyHeader(reader *bufio.Reader) (net.Addr, error) {
+	signature, err := reader.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if string(signature) == proxyV2Signature {
+		return readProxyV2Header(reader)
+	}
+	if strings.HasPrefix(string(signature), proxyV1Prefix) {
+		return readProxyV1Header(reader)
+	}
+	return nil, fmt.Errorf("missing PROXY header")
+}
+
+// readProxyV1Header reads a header like "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readProxyV1Header(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, fmt.Errorf("PROXY v1 header is longer than %d bytes", proxyV1MaxLength)
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("malformed PROXY v1 header %q", strings.TrimSpace(string(line)))
+	}
+
+	ip, err := netip.ParseAddr(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed PROXY v1 source address: %w", err)
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("malformed PROXY v1 source port: %w", err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+func readProxyV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, proxyV2HeaderLength)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+
+	versionCommand, family := header[12], header[13]
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY version %d", versionCommand>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+
+	switch command := versionCommand & 0x0f; command {
+	case proxyV2CommandLocal:
+		return nil, nil
+	case proxyV2CommandProxy:
+	default:
+		return nil, fmt.Errorf("unsupported PROXY v2 command %d", command)
+	}
+
+	// Addresses are followed by optional TLVs, which are ignored
+	var addrLen int
+	switch family {
+	case proxyV2FamilyTCP4:
+		addrLen = net.IPv4len
+	case proxyV2FamilyTCP6:
+		addrLen = net.IPv6len
+	default:
+		return nil, nil
+	}
+	if len(payload) < 2*addrLen+4 {
+		return nil, fmt.Errorf("PROXY v2 address block is too short")
+	}
+
+	ip, _ := netip.AddrFromSlice(payload[:addrLen])
+	port := binary.BigEndian.Uint16(payload[2*addrLen:])
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+}
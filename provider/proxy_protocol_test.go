@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteAddrHandler answers with the remote address of the request.
+var remoteAddrHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.WriteString(w, r.RemoteAddr)
+})
+
+func proxyV2Header(command byte, family byte, addrs []byte) []byte {
+	header := []byte(proxyV2Signature)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func proxyV2TCP4(src, dst string, srcPort, dstPort uint16) []byte {
+	addrs := append(netip.MustParseAddr(src).AsSlice(), netip.MustParseAddr(dst).AsSlice()...)
+	addrs = binary.BigEndian.AppendUint16(addrs, srcPort)
+	return binary.BigEndian.AppendUint16(addrs, dstPort)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	tcp6 := append(netip.MustParseAddr("2001:db8::1").AsSlice(), netip.MustParseAddr("2001:db8::2").AsSlice()...)
+	tcp6 = binary.BigEndian.AppendUint16(tcp6, 4242)
+	tcp6 = binary.BigEndian.AppendUint16(tcp6, 443)
+	// A TLV, which is ignored
+	tcp6 = append(tcp6, 0x04, 0x00, 0x01, 0xff)
+
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "v1 TCP4", header: "PROXY TCP4 203.0.113.7 192.0.2.1 4242 443\r\n", want: "203.0.113.7:4242"},
+		{name: "v1 TCP6", header: "PROXY TCP6 2001:db8::1 2001:db8::2 4242 443\r\n", want: "[2001:db8::1]:4242"},
+		{name: "v1 UNKNOWN", header: "PROXY UNKNOWN\r\n"},
+		{name: "v1 malformed address", header: "PROXY TCP4 not-an-ip 192.0.2.1 4242 443\r\n", wantErr: true},
+		{name: "v1 too long", header: "PROXY TCP4 " + strings.Repeat("1", 200) + "\r\n", wantErr: true},
+		{
+			name:   "v2 TCP4",
+			header: string(proxyV2Header(proxyV2CommandProxy, proxyV2FamilyTCP4, proxyV2TCP4("203.0.113.7", "192.0.2.1", 4242, 443))),
+			want:   "203.0.113.7:4242",
+		},
+		{
+			name:   "v2 TCP6 with TLV",
+			header: string(proxyV2Header(proxyV2CommandProxy, proxyV2FamilyTCP6, tcp6)),
+			want:   "[2001:db8::1]:4242",
+		},
+		{name: "v2 LOCAL", header: string(proxyV2Header(proxyV2CommandLocal, 0, nil))},
+		{
+			name:    "v2 truncated addresses",
+			header:  string(proxyV2Header(proxyV2CommandProxy, proxyV2FamilyTCP4, []byte{1, 2, 3})),
+			wantErr: true,
+		},
+		{name: "missing header", header: "GET / HTTP/1.1\r\n\r\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.header + "payload"))
+
+			addr, err := readProxyHeader(reader)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if tt.want == "" {
+				assert.Nil(t, addr)
+			} else {
+				require.NotNil(t, addr)
+				assert.Equal(t, tt.want, addr.String())
+			}
+
+			rest, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "payload", string(rest), "the header must be consumed exactly")
+		})
+	}
+}
+
+// rawGet sends a GET request after the given prefix, and returns the
+// response body, or an error if the connection was rejected.
+func rawGet(addr net.Addr, prefix []byte) (string, error) {
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	request := append(prefix, "GET / HTTP/1.1\r\nHost: provider\r\nConnection: close\r\n\r\n"...)
+	if _, err := conn.Write(request); err != nil {
+		return "", err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestWithProxyProtocol_UsesClientAddress(t *testing.T) {
+	srv := startServerT(t, remoteAddrHandler, WithProxyProtocol())
+
+	body, err := rawGet(srv.Addr(), []byte("PROXY TCP4 203.0.113.7 192.0.2.1 4242 443\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:4242", body)
+
+	body, err = rawGet(srv.Addr(), proxyV2Header(proxyV2CommandProxy, proxyV2FamilyTCP4,
+		proxyV2TCP4("203.0.113.8", "192.0.2.1", 4343, 443)))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.8:4343", body)
+
+	_, err = rawGet(srv.Addr(), nil)
+	assert.Error(t, err, "connections without header must be rejected")
+}
+
+func TestWithProxyProtocol_UntrustedPeerUsesOwnAddress(t *testing.T) {
+	srv := startServerT(t, remoteAddrHandler, WithProxyProtocol(netip.MustParsePrefix("192.0.2.0/24")))
+
+	body, err := rawGet(srv.Addr(), nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(body, "127.0.0.1:"), body)
+}
+
+func TestWithProxyProtocol_BoundsPendingHeaders(t *testing.T) {
+	srv := startServerT(t, okHandler, WithProxyProtocol(), WithMaxConnections(1))
+
+	// The first connection holds the only slot until its header is read
+	openConn(t, srv.Addr(), nil)
+
+	_, err := rawGet(srv.Addr(), proxyV1Header("203.0.113.7"))
+	assert.Error(t, err)
+}
+
+func TestNewProxyProtocolListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewServer(remoteAddrHandler)
+	go server.Serve(NewProxyProtocolListener(listener, 0))
+	t.Cleanup(func() { server.Close() })
+
+	body, err := rawGet(listener.Addr(), proxyV1Header("203.0.113.7"))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:4242", body)
+
+	_, err = rawGet(listener.Addr(), nil)
+	assert.Error(t, err, "connections without header must be rejected")
+}
+
+func TestBuildServer_FailsWithProxyProtocol(t *testing.T) {
+	server, err := BuildServer(okHandler, WithProxyProtocol())
+	assert.ErrorIs(t, err, ErrStartServerOnly)
+	assert.Nil(t, server)
+}
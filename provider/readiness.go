@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// readinessPollInterval is the delay between two readiness checks
+const readinessPollInterval = 10 * time.Millisecond
+
+// waitUntilServing checks that the server answers requests on its listener,
+// until it does, it fails as reported by serveErr, or ServerStartupTimeout
+// elapses.
+func waitUntilServing(listener *serverListener, useTLS bool, serveErr <-chan error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ServerStartupTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(readinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := checkServing(ctx, listener, useTLS)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case err, ok := <-serveErr:
+			if !ok {
+				return errors.New("server stopped during startup")
+			}
+			return err
+		case <-ctx.Done():
+			return fmt.Errorf("server startup timeout after %v: %w", ServerStartupTimeout, err)
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkServing checks that the listener accepts connections to its address,
+// if it can be dialed, then sends an "OPTIONS *" request, answered by the
+// http.Server itself without reaching the provider handler. The request is
+// sent over an in-memory connection accepted by the server like any other, so
+// it is not subject to the allowed CIDRs and the PROXY protocol.
+func checkServing(ctx context.Context, listener *serverListener, useTLS bool) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	conn, serverConn := net.Pipe()
+	defer conn.Close()
+
+	// The in-memory connection is only accepted once the probe's connection
+	// was, the server cannot be serving it after a failed Accept
+	listener.inject(readinessConn{serverConn})
+	if err := listener.probe(ctx); err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	if useTLS {
+		// The server checks its own certificate, not its identity
+		tlsConn := tls.Client(conn, &tls.Config{
+			InsecureSkipVerify: true, //nolint:gosec
+			NextProtos:         []string{"http/1.1"},
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return tlsReadinessError(err)
+		}
+		conn = tlsConn
+	}
+
+	// The pipe is synchronous, the request is written while reading, as the
+	// server may reject the connection with an alert before reading it
+	go fmt.Fprintf(conn, "OPTIONS * HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", listener.Addr())
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		return tlsReadinessError(err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// readinessConn is the in-memory connection of the readiness check, which
+// is not counted by the connection limits.
+type readinessConn struct {
+	net.Conn
+}
+
+// isReadinessConn reports whether conn is the readiness check connection,
+// possibly wrapped by the TLS listener of the server.
+func isReadinessConn(conn net.Conn) bool {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	_, ok := conn.(readinessConn)
+	return ok
+}
+
+// tlsReadinessError accepts TLS alerts from the server, such as the
+// rejection of the missing client certificate with mutual TLS, which prove
+// that the server is serving the connection.
+func tlsReadinessError(err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return nil
+	}
+	return err
+}
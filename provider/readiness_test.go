@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errAcceptFailed = errors.New("accept failed")
+
+// failingListener fails to accept connections once fail is set.
+type failingListener struct {
+	net.Listener
+	fail atomic.Bool
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if l.fail.Load() {
+		return nil, errAcceptFailed
+	}
+	conn, err := l.Listener.Accept()
+	if err == nil && l.fail.Load() {
+		conn.Close()
+		return nil, errAcceptFailed
+	}
+	return conn, err
+}
+
+// wakeAccept connects to the listener, so a pending Accept returns. The
+// connection may be refused if the server already stopped.
+func wakeAccept(listener net.Listener) {
+	if conn, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		conn.Close()
+	}
+}
+
+func newFailingListener(t *testing.T) *failingListener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	return &failingListener{Listener: listener}
+}
+
+func TestStartServer_ReportsEarlyServeFailure(t *testing.T) {
+	listener := newFailingListener(t)
+	listener.fail.Store(true)
+
+	srv, err := StartServer(okHandler, WithListener(listener))
+	require.Error(t, err)
+	assert.Nil(t, srv)
+	assert.ErrorIs(t, err, errAcceptFailed)
+}
+
+func TestStartServer_ErrReportsServeFailure(t *testing.T) {
+	listener := newFailingListener(t)
+
+	srv := startServerT(t, okHandler, WithListener(listener))
+
+	listener.fail.Store(true)
+	wakeAccept(listener)
+
+	select {
+	case err := <-srv.Err():
+		assert.ErrorIs(t, err, errAcceptFailed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the serve failure was not reported")
+	}
+}
+
+func TestStartServer_ErrClosedOnShutdown(t *testing.T) {
+	srv, err := StartServer(okHandler, WithAddr("127.0.0.1:0"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	err, ok := <-srv.Err()
+	assert.False(t, ok)
+	assert.NoError(t, err)
+}
+
+func TestStartServer_ReadyWithTLS(t *testing.T) {
+	addr, _, _ := startTLSReloadServer(t)
+
+	// The server completes handshakes as soon as StartServer returned
+	assert.Equal(t, int64(1), servedSerial(t, addr))
+}
+
+func TestRun_ReturnsServeFailure(t *testing.T) {
+	listener := newFailingListener(t)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- Run(context.Background(), okHandler, WithListener(listener))
+	}()
+	require.Equal(t, http.StatusOK, getStatus(t, dialAddr(listener.Addr())))
+
+	listener.fail.Store(true)
+	wakeAccept(listener)
+
+	select {
+	case err := <-result:
+		assert.ErrorIs(t, err, errAcceptFailed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the server failed")
+	}
+}
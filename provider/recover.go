@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/internal/correlation"
+)
+
+// PanicHook is called when a provider service handler panics. It receives the
+// recovered value, the stack trace of the panicking goroutine, and the
+// correlation ID which was returned to the network instead of the panic details.
+type PanicHook func(ctx context.Context, procedure string, correlationID string, recovered any, stack []byte)
+
+// defaultPanicHook logs the panic using the default slog logger.
+func defaultPanicHook(ctx context.Context, procedure string, correlationID string, recovered any, stack []byte) {
+	slog.ErrorContext(ctx, "provider handler panicked",
+		slog.String("procedure", procedure),
+		slog.String("correlation_id", correlationID),
+		slog.Any("panic", recovered),
+		slog.String("stack", string(stack)),
+	)
+}
+
+// newRecoverHandlerOption converts panics of the provider service handlers to
+// CodeInternal errors. The error sent to the network only carries a
+// correlation ID, the panic details are passed to the hook.
+func newRecoverHandlerOption(hook PanicHook) connect.HandlerOption {
+	return connect.WithRecover(func(ctx context.Context, spec connect.Spec, _ http.Header, recovered any) error {
+		stack := debug.Stack()
+		correlationID := correlation.NewID()
+
+		if hook != nil {
+			hook(ctx, spec.Procedure, correlationID, recovered, stack)
+		}
+
+		connectErr := connect.NewError(connect.CodeInternal,
+			fmt.Errorf("internal error, correlation id: %s", correlationID))
+		connectErr.Meta().Set(common.CorrelationIDHeader, correlationID)
+		return connectErr
+	})
+}
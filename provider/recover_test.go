@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/common"
+	"github.com/t-0-network/provider-sdk-go/network"
+)
+
+type panickingProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+}
+
+func (panickingProvider) PayOut(
+	context.Context, *connect.Request[payment.PayoutRequest],
+) (*connect.Response[payment.PayoutResponse], error) {
+	panic("bank credentials: hunter2")
+}
+
+func TestRecover_PanicIsConvertedToInternalError(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+
+	var (
+		hookCalled        bool
+		hookProcedure     string
+		hookCorrelationID string
+		hookRecovered     any
+		hookStack         []byte
+	)
+	hook := func(_ context.Context, procedure, correlationID string, recovered any, stack []byte) {
+		hookCalled = true
+		hookProcedure, hookCorrelationID, hookRecovered, hookStack = procedure, correlationID, recovered, stack
+	}
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(panickingProvider{}),
+			WithPanicHook(hook),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = client.PayOut(context.Background(), connect.NewRequest(&payment.PayoutRequest{}))
+	require.Error(t, err)
+
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, connect.CodeInternal, connectErr.Code())
+	assert.NotContains(t, connectErr.Message(), "hunter2")
+
+	require.True(t, hookCalled)
+	assert.Equal(t, paymentconnect.ProviderServicePayOutProcedure, hookProcedure)
+	assert.Equal(t, "bank credentials: hunter2", hookRecovered)
+	assert.Contains(t, string(hookStack), "panickingProvider.PayOut")
+	assert.NotEmpty(t, hookCorrelationID)
+	assert.Contains(t, connectErr.Message(), hookCorrelationID)
+	assert.Equal(t, hookCorrelationID, connectErr.Meta().Get(common.CorrelationIDHeader))
+}
+
+func TestRecover_EnabledByDefault(t *testing.T) {
+	opts, err := newDefaultHandlerOptions(nil)
+	require.NoError(t, err)
+	assert.True(t, opts.recoverPanics)
+	assert.NotNil(t, opts.panicHook)
+
+	WithoutPanicRecovery()(&opts)
+	assert.False(t, opts.recoverPanics)
+}
@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Run starts the server with the provided handler and blocks until ctx is
+// cancelled, the process receives SIGINT or SIGTERM, or the server fails.
+// The server is then shut down gracefully within the shutdown timeout,
+// calling the hooks registered with WithPreShutdownHook and WithPostShutdownHook.
+//
+// It returns the startup error, or the server failure and the aggregated
+// shutdown errors. A second signal during the shutdown terminates the process.
+//
+// Example:
+//
+//	if err := provider.Run(context.Background(), handler,
+//	    provider.WithAddr(":8080"),
+//	    provider.WithPostShutdownHook(publisher.Stop),
+//	); err != nil {
+//	    log.Fatal(err)
+//	}
+func Run(ctx context.Context, handler http.Handler, serverOptions ...ServerOption) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server, err := StartServer(handler, serverOptions...)
+	if err != nil {
+		return err
+	}
+
+	var serveErr error
+	select {
+	case <-ctx.Done():
+	case serveErr = <-server.Err():
+	}
+	// Restore the default behavior, so a second signal terminates the process
+	stop()
+
+	// The shutdown is bounded by the shutdown timeout, not by the done context
+	return errors.Join(serveErr, server.Shutdown(context.WithoutCancel(ctx)))
+}
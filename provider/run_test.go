@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runInBackground runs the server on a new listener until the returned
+// channel receives the result of Run.
+func runInBackground(t *testing.T, ctx context.Context, opts ...ServerOption) (net.Addr, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	result := make(chan error, 1)
+	go func() {
+		result <- Run(ctx, okHandler, append(opts, WithListener(listener))...)
+	}()
+
+	require.Equal(t, http.StatusOK, getStatus(t, dialAddr(listener.Addr())))
+	return listener.Addr(), result
+}
+
+func TestRun_ShutsDownOnContextCancel(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	record := func(name string) ShutdownHook {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	addr, result := runInBackground(t, ctx,
+		WithPreShutdownHook(record("pre 1")),
+		WithPreShutdownHook(record("pre 2")),
+		WithPostShutdownHook(record("post")),
+	)
+
+	cancel()
+	select {
+	case err := <-result:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+
+	assert.Equal(t, []string{"pre 1", "pre 2", "post"}, calls)
+	_, err := net.Dial(addr.Network(), addr.String())
+	assert.Error(t, err, "the server must not accept connections anymore")
+}
+
+func TestRun_AggregatesHookErrors(t *testing.T) {
+	errFlush := errors.New("flushing ledger")
+	errStop := errors.New("stopping publisher")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, result := runInBackground(t, ctx,
+		WithPreShutdownHook(func(context.Context) error { return errFlush }),
+		WithPostShutdownHook(func(context.Context) error { return errStop }),
+	)
+
+	cancel()
+	err := <-result
+	assert.ErrorIs(t, err, errFlush)
+	assert.ErrorIs(t, err, errStop)
+}
+
+func TestRun_HooksBoundedByShutdownTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, result := runInBackground(t, ctx,
+		WithShutdownTimeout(100*time.Millisecond),
+		WithPostShutdownHook(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	)
+
+	cancel()
+	start := time.Now()
+	assert.ErrorIs(t, <-result, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestRun_StartupError(t *testing.T) {
+	err := Run(context.Background(), okHandler, WithAddr("invalid:address"))
+	assert.ErrorContains(t, err, "failed to create listener")
+}
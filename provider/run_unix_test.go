@@ -0,0 +1,26 @@
+//go:build unix
+
+package provider
+
+import (
+	"context"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_ShutsDownOnSIGTERM(t *testing.T) {
+	// The signal handler is registered once the server accepts connections
+	_, result := runInBackground(t, context.Background())
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case err := <-result:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after SIGTERM")
+	}
+}
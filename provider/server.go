@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"sync"
@@ -16,12 +17,13 @@ import (
 
 // Default timeout values
 const (
-	DefaultAddr              = ":8080"
-	DefaultReadTimeout       = 10 * time.Second
-	DefaultWriteTimeout      = 10 * time.Second
-	DefaultReadHeaderTimeout = 10 * time.Second
-	DefaultShutdownTimeout   = 15 * time.Second
-	ServerStartupTimeout     = 5 * time.Second
+	DefaultAddr               = ":8080"
+	DefaultReadTimeout        = 10 * time.Second
+	DefaultWriteTimeout       = 10 * time.Second
+	DefaultReadHeaderTimeout  = 10 * time.Second
+	DefaultShutdownTimeout    = 15 * time.Second
+	ServerStartupTimeout      = 5 * time.Second
+	DefaultCertReloadInterval = time.Minute
 )
 
 // ServerOption configures server options using the functional options pattern
@@ -29,16 +31,36 @@ type ServerOption func(*serverOptions)
 
 type serverOptions struct {
 	addr              string
+	listener          net.Listener
 	readTimeout       time.Duration
 	writeTimeout      time.Duration
 	readHeaderTimeout time.Duration
 	tlsConfig         *tls.Config
 	shutdownTimeout   time.Duration // applies only to started server
 	http2Config       *http2.Server
+	newUDPServer      NewUDPServer
+	metrics           *metricsOptions
+	admin             *adminOptions
+	clientAuth        *clientAuthOptions
+	connGuard         connGuardOptions
+	proxyProtocol     *proxyProtocolOptions
+
+	tlsCertFiles       *tlsCertFilesOptions
+	certReloadInterval time.Duration
+	onCertReloadError  func(error)
+	certReloader       *certReloader // built by createServer from tlsCertFiles
+
+	preShutdownHooks  []ShutdownHook
+	postShutdownHooks []ShutdownHook
+	drainer           *Drainer
 }
 
 // WithAddr sets the server's address to listen on (host:port format)
 // If an empty string is provided, the default ":8080" will be used
+//
+// StartServer also accepts "unix:<path>" to listen on a Unix socket, and
+// "systemd:" or "systemd:<name>" to use a socket passed by systemd socket
+// activation, the first one or the one with the given FileDescriptorName.
 func WithAddr(addr string) ServerOption {
 	return func(opts *serverOptions) {
 		if addr != "" {
@@ -47,6 +69,16 @@ func WithAddr(addr string) ServerOption {
 	}
 }
 
+// WithListener makes StartServer serve on the given listener instead of
+// listening on the configured address. The listener is closed on shutdown.
+// Its address does not have to be dialable, such as the one of an in-memory
+// listener: the readiness check of StartServer then skips the dial.
+func WithListener(listener net.Listener) ServerOption {
+	return func(opts *serverOptions) {
+		opts.listener = listener
+	}
+}
+
 // WithReadTimeout sets the maximum duration for reading the entire request
 // including the body. A timeout of 0 means no timeout.
 // Negative timeouts are ignored and the default will be used.
@@ -61,6 +93,8 @@ func WithReadTimeout(timeout time.Duration) ServerOption {
 }
 
 // WithWriteTimeout sets the maximum duration before timing out writes of the response
+// Provider handler deadlines are capped to expire before the write timeout,
+// so the network gets a DeadlineExceeded error rather than a dropped connection.
 // A timeout of 0 means no timeout.
 // Negative timeouts are ignored and the default will be used.
 func WithWriteTimeout(timeout time.Duration) ServerOption {
@@ -93,6 +127,46 @@ func WithTLSConfig(tlsConfig *tls.Config) ServerOption {
 	}
 }
 
+// WithTLSCertFiles serves TLS with the certificate and key loaded from the
+// given PEM files. The files are watched for changes, and the certificate is
+// also reloaded when the process receives SIGHUP, so certificates can be
+// rotated without a restart. Established connections keep their certificate.
+//
+// The certificate is loaded when the server is created: StartServer and
+// BuildServer return the error if it cannot be.
+//
+// It can be combined with WithTLSConfig, whose certificates are then replaced.
+// SIGHUP handling and the polling of the files require StartServer. Servers
+// created by NewServer only check the files for changes lazily, during TLS
+// handshakes, at most once per WithCertReloadInterval.
+func WithTLSCertFiles(certPath, keyPath string) ServerOption {
+	return func(opts *serverOptions) {
+		opts.tlsCertFiles = &tlsCertFilesOptions{
+			certPath: certPath,
+			keyPath:  keyPath,
+		}
+	}
+}
+
+// WithCertReloadInterval sets how often the files set by WithTLSCertFiles are
+// checked for changes. If the interval is <= 0, the default interval will be used.
+func WithCertReloadInterval(interval time.Duration) ServerOption {
+	return func(opts *serverOptions) {
+		if interval > 0 {
+			opts.certReloadInterval = interval
+		}
+	}
+}
+
+// WithCertReloadErrorHandler sets the callback receiving the errors of
+// reloading the files set by WithTLSCertFiles, after the initial load. The previous certificate keeps
+// being served after a failed reload.
+func WithCertReloadErrorHandler(fn func(error)) ServerOption {
+	return func(opts *serverOptions) {
+		opts.onCertReloadError = fn
+	}
+}
+
 // WithShutdownTimeout sets the maximum duration to wait for the server to shutdown gracefully
 // If the timeout is <= 0, the default timeout will be used.
 func WithShutdownTimeout(timeout time.Duration) ServerOption {
@@ -103,6 +177,30 @@ func WithShutdownTimeout(timeout time.Duration) ServerOption {
 	}
 }
 
+// ShutdownHook is called during the graceful shutdown of a started server,
+// with the shutdown context.
+type ShutdownHook func(ctx context.Context) error
+
+// WithPreShutdownHook registers a hook called before the server stops
+// accepting requests, for instance to report the service as not ready.
+// Hooks are called in registration order, and their errors are returned by
+// the shutdown along with the server ones.
+func WithPreShutdownHook(hook ShutdownHook) ServerOption {
+	return func(opts *serverOptions) {
+		opts.preShutdownHooks = append(opts.preShutdownHooks, hook)
+	}
+}
+
+// WithPostShutdownHook registers a hook called once in-flight requests are
+// done, for instance to flush ledgers or stop a quote publisher.
+// Hooks are called in registration order, and their errors are returned by
+// the shutdown along with the server ones.
+func WithPostShutdownHook(hook ShutdownHook) ServerOption {
+	return func(opts *serverOptions) {
+		opts.postShutdownHooks = append(opts.postShutdownHooks, hook)
+	}
+}
+
 // WithHTTP2Config sets custom HTTP/2 server configuration
 func WithHTTP2Config(config *http2.Server) ServerOption {
 	return func(opts *serverOptions) {
@@ -120,6 +218,8 @@ var defaultServerOptions = serverOptions{
 	tlsConfig:         nil,
 	shutdownTimeout:   DefaultShutdownTimeout,
 	http2Config:       &http2.Server{},
+
+	certReloadInterval: DefaultCertReloadInterval,
 }
 
 // ServerShutdownFn is a function that gracefully shuts down the server.
@@ -127,21 +227,110 @@ var defaultServerOptions = serverOptions{
 // It is safe to call concurrently, but only the first call is guaranteed to succeed.
 type ServerShutdownFn func(ctx context.Context) error
 
+// RunningServer is a server started by StartServer.
+type RunningServer struct {
+	listener net.Listener
+	admin    []*http.Server // admin and metrics servers, the admin one last
+	shutdown ServerShutdownFn
+	err      <-chan error
+}
+
+// Addr returns the address the server is bound to, for instance the port
+// picked for WithAddr(":0").
+func (s *RunningServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Err returns a channel receiving the error of the server, or of its admin,
+// metrics or UDP server, if it stops serving for another reason than a
+// shutdown. It is closed once the servers stopped.
+func (s *RunningServer) Err() <-chan error {
+	return s.err
+}
+
+// Shutdown gracefully shuts down the server, see ServerShutdownFn.
+func (s *RunningServer) Shutdown(ctx context.Context) error {
+	return s.shutdown(ctx)
+}
+
 // NewServer returns a ready-to-use *http.Server with the provided handler registered.
 // The server is not started - you need to call ListenAndServe or similar methods.
+// It panics if the handler is nil.
+//
+// If the options cannot be applied, see BuildServer, the error is logged with
+// slog and the returned server answers every request with 503 Service
+// Unavailable. Use BuildServer to handle the error instead.
 func NewServer(handler http.Handler, serverOptions ...ServerOption) *http.Server {
 	if handler == nil {
 		panic("handler cannot be nil")
 	}
 
-	server, _ := createServer(handler, serverOptions)
+	server, err := BuildServer(handler, serverOptions...)
+	if err != nil {
+		slog.Error("invalid provider server options, the server is unavailable", "error", err)
+		return unavailableServer(serverOptions)
+	}
 	return server
 }
 
+// BuildServer returns a ready-to-use *http.Server with the provided handler
+// registered, like NewServer, or the error of the options: the metrics of
+// WithMetrics cannot be registered, the certificate of WithTLSCertFiles cannot
+// be loaded, the client CAs are missing, or an option requires StartServer,
+// such as WithProxyProtocol and WithUDPServer.
+//
+// Example:
+//
+//	server, err := provider.BuildServer(handler, provider.WithTLSCertFiles(certPath, keyPath))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = server.ListenAndServeTLS("", "")
+func BuildServer(handler http.Handler, serverOptions ...ServerOption) (*http.Server, error) {
+	if handler == nil {
+		return nil, fmt.Errorf("handler cannot be nil")
+	}
+
+	server, opts, err := createServer(handler, serverOptions)
+	if err != nil {
+		return nil, err
+	}
+	if opts.proxyProtocol != nil {
+		return nil, fmt.Errorf("%w: WithProxyProtocol, wrap the listener with NewProxyProtocolListener instead", ErrStartServerOnly)
+	}
+	if opts.newUDPServer != nil {
+		return nil, fmt.Errorf("%w: WithUDPServer", ErrStartServerOnly)
+	}
+	return server, nil
+}
+
+// unavailableServer returns a server with the address and the timeouts of the
+// options, answering every request with 503 Service Unavailable.
+func unavailableServer(serverOptions []ServerOption) *http.Server {
+	opts := defaultServerOptions
+	for _, opt := range serverOptions {
+		opt(&opts)
+	}
+
+	return &http.Server{
+		Addr:              opts.addr,
+		ReadTimeout:       opts.readTimeout,
+		ReadHeaderTimeout: opts.readHeaderTimeout,
+		WriteTimeout:      opts.writeTimeout,
+		TLSConfig:         opts.tlsConfig,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}),
+	}
+}
+
 // StartServer creates and starts a new HTTP server with the provided handler.
 //
-// The server starts asynchronously and this function returns immediately after
-// confirming the server is ready to accept connections or after ServerStartupTimeout.
+// The server starts asynchronously and this function returns once the server
+// accepted a connection to its own address, if it can be dialed, and answered
+// a request over an in-memory connection, including the TLS handshake if TLS
+// is configured, or fails after ServerStartupTimeout.
+// Later failures of the server are reported by RunningServer.Err.
 //
 // Example:
 //
@@ -149,78 +338,116 @@ func NewServer(handler http.Handler, serverOptions ...ServerOption) *http.Server
 //	    w.WriteHeader(http.StatusOK)
 //	})
 //
-//	shutdown, err := StartServer(handler,
+//	server, err := StartServer(handler,
 //	    WithAddr(":8080"),
 //	    WithReadTimeout(30*time.Second),
 //	)
 //	if err != nil {
 //	    log.Fatal(err)
 //	}
-//	defer shutdown(context.Background())
+//	defer server.Shutdown(context.Background())
 //
 // Returns:
-//   - ServerShutdownFn: Safe for concurrent use, only first call performs shutdown
+//   - *RunningServer: Bound address and shutdown, safe for concurrent use, only first call performs shutdown
 //   - error: Non-nil if server failed to start or bind to address
-func StartServer(handler http.Handler, serverOptions ...ServerOption) (ServerShutdownFn, error) {
+func StartServer(handler http.Handler, serverOptions ...ServerOption) (*RunningServer, error) {
 	if handler == nil {
 		return nil, fmt.Errorf("handler cannot be nil")
 	}
 
-	server, opts := createServer(handler, serverOptions)
-	listener, err := createListener(server.Addr)
+	server, opts, err := createServer(handler, serverOptions)
 	if err != nil {
 		return nil, err
 	}
+	if opts.clientAuth != nil && opts.tlsConfig == nil && opts.tlsCertFiles == nil {
+		return nil, fmt.Errorf("client certificate authentication requires TLS to be configured")
+	}
 
-	// Channels to communicate server startup status
-	startupErr := make(chan error, 1)
-	startupReady := make(chan struct{})
-
-	// Wait group for graceful shutdown
-	var wg sync.WaitGroup
-	// Once to ensure server shutdown is only executed once
-	var shutdownOnce sync.Once
+	baseListener, err := createListener(opts)
+	if err != nil {
+		return nil, err
+	}
+	listener := newServerListener(baseListener, logRejectedConn)
+	if opts.proxyProtocol != nil {
+		opts.proxyProtocol.wrap(listener, opts.readHeaderTimeout, opts.connGuard.maxConns)
+	}
+	listener.start()
 
-	wg.Add(1)
+	var udpServer UDPServer
+	var udpConn net.PacketConn
+	if opts.newUDPServer != nil {
+		if udpServer, err = opts.newUDPServer(server.Handler, server.TLSConfig); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("creating UDP server: %w", err)
+		}
+		if udpConn, err = listenUDP(listener.Addr(), opts.connGuard.allowedCIDRs); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to create UDP listener: %w", err)
+		}
+		server.Handler = announceMiddleware(udpServer)(server.Handler)
+	}
 
-	go func() {
-		defer wg.Done()
+	// Stops the background work bound to the server lifetime
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	if opts.certReloader != nil {
+		opts.certReloader.watch(backgroundCtx)
+	}
 
-		// Signal that server is ready to accept connections
-		close(startupReady)
+	// Runs the Serve loops, its errors are closed once the servers stopped
+	servers := newServeGroup()
 
-		var err error
-		if opts.tlsConfig != nil {
-			err = server.ServeTLS(listener, "", "")
-		} else {
-			err = server.Serve(listener)
+	adminServers, err := startAdminServers(opts, effectiveConfig{
+		Server:   opts.describeServer(listener.Addr().String()),
+		Handlers: describeHandlers(handler),
+	}, servers)
+	if err != nil {
+		stopBackground()
+		listener.Close()
+		if udpConn != nil {
+			udpConn.Close()
 		}
+		return nil, fmt.Errorf("starting admin server: %w", err)
+	}
 
-		// Only report startup errors, ignore shutdown errors
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			select {
-			case startupErr <- err:
-			default:
-				// Startup phase is over, ignore the error
-				// The server.Shutdown() call will handle cleanup
-			}
-		}
-	}()
+	// Once to ensure server shutdown is only executed once
+	var shutdownOnce sync.Once
 
-	// Wait for server to be ready, error out, or timeout
-	select {
-	case <-startupReady:
-		// Server is ready to accept connections
-	case err := <-startupErr:
-		if err != nil {
-			// Server failed to start, close listener and return error
-			listener.Close()
-			return nil, fmt.Errorf("failed to start provider server on %s: %w", server.Addr, err)
+	// Serving configures the TLS config of the server, read it beforehand
+	useTLS := server.TLSConfig != nil
+
+	servers.serve(func() error {
+		if useTLS {
+			return server.ServeTLS(listener, "", "")
 		}
-	case <-time.After(ServerStartupTimeout):
-		// Timeout waiting for server to be ready
+		return server.Serve(listener)
+	})
+
+	if udpConn != nil {
+		servers.serve(func() error {
+			err := udpServer.Serve(udpConn)
+			if err != nil && !errors.Is(err, net.ErrClosed) {
+				return fmt.Errorf("serving UDP: %w", err)
+			}
+			return nil
+		})
+	}
+
+	servers.closeWhenDone()
+
+	// Wait for the server to answer requests, fail, or time out
+	if err := waitUntilServing(listener, useTLS, servers.errs); err != nil {
+		// Server failed to start, stop it and return error
+		stopBackground()
+		server.Close()
 		listener.Close()
-		return nil, fmt.Errorf("server startup timeout after %v on %s", ServerStartupTimeout, server.Addr)
+		if udpConn != nil {
+			udpServer.Close()
+			udpConn.Close()
+		}
+		for _, adminServer := range adminServers {
+			adminServer.Close()
+		}
+		return nil, fmt.Errorf("failed to start provider server on %s: %w", listener.Addr(), err)
 	}
 
 	// Create a reusable shutdown function that can be called concurrently
@@ -250,20 +477,60 @@ func StartServer(handler http.Handler, serverOptions ...ServerOption) (ServerShu
 			}
 			defer cancel()
 
+			// Readiness goes false first, so load balancers stop sending traffic
+			if opts.drainer != nil {
+				opts.drainer.startDraining()
+			}
+
+			var errs []error
+			for _, hook := range opts.preShutdownHooks {
+				if err := hook(timeoutCtx); err != nil {
+					errs = append(errs, fmt.Errorf("pre-shutdown hook: %w", err))
+				}
+			}
+
 			// Shutdown the server gracefully
 			if err := server.Shutdown(timeoutCtx); err != nil {
-				shutdownErr = fmt.Errorf("http server shutdown: %w", err)
+				errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
+			}
+			if udpConn != nil {
+				if err := udpServer.Shutdown(timeoutCtx); err != nil {
+					errs = append(errs, fmt.Errorf("UDP server shutdown: %w", err))
+				}
+				udpConn.Close()
 			}
 
 			// Always ensure listener is closed
 			if listener != nil {
 				listener.Close()
 			}
+			stopBackground()
+
+			// In-flight requests are done, wait for the background tasks they started
+			if opts.drainer != nil {
+				if err := opts.drainer.wait(timeoutCtx); err != nil {
+					errs = append(errs, fmt.Errorf("draining background tasks: %w", err))
+				}
+			}
+
+			for _, hook := range opts.postShutdownHooks {
+				if err := hook(timeoutCtx); err != nil {
+					errs = append(errs, fmt.Errorf("post-shutdown hook: %w", err))
+				}
+			}
+
+			// The admin servers go last, so the shutdown itself is still observable
+			for _, adminServer := range adminServers {
+				if err := adminServer.Shutdown(timeoutCtx); err != nil {
+					errs = append(errs, fmt.Errorf("admin server shutdown: %w", err))
+				}
+			}
+			shutdownErr = errors.Join(errs...)
 
 			// Wait for the server goroutine to finish with timeout
 			done := make(chan struct{})
 			go func() {
-				wg.Wait()
+				servers.wg.Wait()
 				close(done)
 			}()
 
@@ -282,36 +549,95 @@ func StartServer(handler http.Handler, serverOptions ...ServerOption) (ServerShu
 		return shutdownErr
 	}
 
-	// Close startup error channel to prevent goroutine leaks
-	close(startupErr)
+	return &RunningServer{listener: listener, admin: adminServers, shutdown: serverShutdown, err: servers.errs}, nil
+}
+
+// maxServers bounds the servers started by StartServer: the provider server,
+// its UDP server, the metrics server and the admin one.
+const maxServers = 4
 
-	return serverShutdown, nil
+// serveGroup runs the Serve loops of the servers started by StartServer, and
+// reports their failures, other than a shutdown, on errs.
+type serveGroup struct {
+	wg   sync.WaitGroup
+	errs chan error
 }
 
-// createListener creates a TCP listener for the given address
-func createListener(addr string) (net.Listener, error) {
-	listener, err := net.Listen("tcp", addr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create listener on %s: %w", addr, err)
-	}
-	return listener, nil
+func newServeGroup() *serveGroup {
+	// Every server reports at most one error, the sends never block
+	return &serveGroup{errs: make(chan error, maxServers)}
+}
+
+// serve runs the Serve loop in the background.
+func (g *serveGroup) serve(serve func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			g.errs <- err
+		}
+	}()
+}
+
+// closeWhenDone closes errs once every Serve loop returned. No server must be
+// started afterwards.
+func (g *serveGroup) closeWhenDone() {
+	go func() {
+		g.wg.Wait()
+		close(g.errs)
+	}()
 }
 
 // createServer creates a new http.Server with the provided handler and options
 // This is an internal helper to avoid code duplication
-func createServer(handler http.Handler, options []ServerOption) (*http.Server, *serverOptions) {
+func createServer(handler http.Handler, options []ServerOption) (*http.Server, *serverOptions, error) {
 	// Process options once and store them for later use
 	opts := defaultServerOptions
 	for _, opt := range options {
 		opt(&opts)
 	}
 
-	return &http.Server{
+	handler = newWriteDeadlineMiddleware(opts.writeTimeout)(handler)
+
+	if opts.metrics != nil {
+		metrics, err := newServerMetrics(opts.metrics.registry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("registering provider server metrics: %w", err)
+		}
+		handler = metrics.middleware()(handler)
+	}
+
+	tlsConfig := opts.tlsConfig
+	if opts.tlsCertFiles != nil {
+		certFiles := *opts.tlsCertFiles
+		certFiles.reloadInterval = opts.certReloadInterval
+		certFiles.onReloadError = opts.onCertReloadError
+
+		certReloader, err := newCertReloader(certFiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.certReloader = certReloader
+		tlsConfig = opts.certReloader.tlsConfig(opts.tlsConfig)
+	}
+	if opts.clientAuth != nil {
+		var err error
+		if tlsConfig, err = opts.clientAuth.tlsConfig(tlsConfig); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	server := &http.Server{
 		Addr:              opts.addr,
 		ReadTimeout:       opts.readTimeout,
 		ReadHeaderTimeout: opts.readHeaderTimeout,
 		WriteTimeout:      opts.writeTimeout,
-		TLSConfig:         opts.tlsConfig,
+		TLSConfig:         tlsConfig,
 		Handler:           h2c.NewHandler(handler, opts.http2Config),
-	}, &opts
+	}
+	if opts.connGuard.enabled() {
+		server.ConnState = newConnGuard(opts.connGuard).connState
+	}
+
+	return server, &opts, nil
 }
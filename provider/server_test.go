@@ -19,15 +19,15 @@ func TestStartServer_Success(t *testing.T) {
 		w.Write([]byte("OK"))
 	})
 
-	shutdownFn, err := StartServer(handler, WithAddr(":0")) // Use port 0 for automatic port assignment
+	srv, err := StartServer(handler, WithAddr(":0")) // Use port 0 for automatic port assignment
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// Test shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = shutdownFn(ctx)
+	err = srv.Shutdown(ctx)
 	assert.NoError(t, err)
 }
 
@@ -37,9 +37,9 @@ func TestStartServer_InvalidAddress(t *testing.T) {
 	})
 
 	// Try to bind to an invalid address
-	shutdownFn, err := StartServer(handler, WithAddr("invalid:address"))
+	srv, err := StartServer(handler, WithAddr("invalid:address"))
 	assert.Error(t, err)
-	assert.Nil(t, shutdownFn)
+	assert.Nil(t, srv)
 	assert.Contains(t, err.Error(), "failed to create listener")
 }
 
@@ -49,23 +49,23 @@ func TestStartServer_PortAlreadyInUse(t *testing.T) {
 	})
 
 	// Start first server
-	shutdownFn1, err := StartServer(handler, WithAddr(":0"))
+	srv1, err := StartServer(handler, WithAddr(":0"))
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn1)
+	require.NotNil(t, srv1)
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		shutdownFn1(ctx)
+		srv1.Shutdown(ctx)
 	}()
 
 	// Try to start second server on same port (this should work with port 0)
-	shutdownFn2, err := StartServer(handler, WithAddr(":0"))
+	srv2, err := StartServer(handler, WithAddr(":0"))
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn2)
+	require.NotNil(t, srv2)
 	defer func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
-		shutdownFn2(ctx)
+		srv2.Shutdown(ctx)
 	}()
 }
 
@@ -74,7 +74,7 @@ func TestStartServer_WithOptions(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	shutdownFn, err := StartServer(
+	srv, err := StartServer(
 		handler,
 		WithAddr(":0"),
 		WithReadTimeout(5*time.Second),
@@ -83,12 +83,12 @@ func TestStartServer_WithOptions(t *testing.T) {
 		WithShutdownTimeout(10*time.Second),
 	)
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	err = shutdownFn(ctx)
+	err = srv.Shutdown(ctx)
 	assert.NoError(t, err)
 }
 
@@ -97,13 +97,13 @@ func TestStartServer_ShutdownTimeout(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	shutdownFn, err := StartServer(
+	srv, err := StartServer(
 		handler,
 		WithAddr(":0"),
 		WithShutdownTimeout(100*time.Millisecond), // Very short timeout
 	)
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// Give the server a moment to start
 	time.Sleep(100 * time.Millisecond)
@@ -112,7 +112,7 @@ func TestStartServer_ShutdownTimeout(t *testing.T) {
 	defer cancel()
 
 	start := time.Now()
-	err = shutdownFn(ctx)
+	err = srv.Shutdown(ctx)
 	duration := time.Since(start)
 
 	// The shutdown should complete quickly due to our short shutdown timeout
@@ -125,9 +125,9 @@ func TestStartServer_ConcurrentShutdown(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	shutdownFn, err := StartServer(handler, WithAddr(":0"))
+	srv, err := StartServer(handler, WithAddr(":0"))
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// Test concurrent shutdown calls
 	var wg sync.WaitGroup
@@ -139,7 +139,7 @@ func TestStartServer_ConcurrentShutdown(t *testing.T) {
 			defer wg.Done()
 			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 			defer cancel()
-			errors[index] = shutdownFn(ctx)
+			errors[index] = srv.Shutdown(ctx)
 		}(i)
 	}
 
@@ -367,9 +367,9 @@ func TestNewServerWithCustomOptions(t *testing.T) {
 }
 
 func TestStartServerNilHandler(t *testing.T) {
-	shutdownFn, err := StartServer(nil)
+	srv, err := StartServer(nil)
 	assert.Error(t, err)
-	assert.Nil(t, shutdownFn)
+	assert.Nil(t, srv)
 	assert.Contains(t, err.Error(), "handler cannot be nil")
 }
 
@@ -378,22 +378,22 @@ func TestStartServerCancelledContextShutdown(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	shutdownFn, err := StartServer(handler, WithAddr(":0"))
+	srv, err := StartServer(handler, WithAddr(":0"))
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// Create an already cancelled context
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel() // Cancel immediately
 
-	err = shutdownFn(ctx)
+	err = srv.Shutdown(ctx)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "context already done")
 
 	// Properly shutdown with fresh context
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel2()
-	err = shutdownFn(ctx2)
+	err = srv.Shutdown(ctx2)
 	assert.NoError(t, err)
 }
 
@@ -403,19 +403,19 @@ func TestStartServerShutdownRespectsCaller_Context(t *testing.T) {
 	})
 
 	// Start server with long shutdown timeout
-	shutdownFn, err := StartServer(handler,
+	srv, err := StartServer(handler,
 		WithAddr(":0"),
 		WithShutdownTimeout(30*time.Second), // Long server timeout
 	)
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// But use a short caller timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
 	defer cancel()
 
 	start := time.Now()
-	err = shutdownFn(ctx)
+	err = srv.Shutdown(ctx)
 	duration := time.Since(start)
 
 	// Should respect caller's short timeout, not server's long timeout
@@ -427,20 +427,20 @@ func TestStartServerMultipleShutdownCallsSafeAndIdempotent(t *testing.T) {
 		w.WriteHeader(http.StatusOK)
 	})
 
-	shutdownFn, err := StartServer(handler, WithAddr(":0"))
+	srv, err := StartServer(handler, WithAddr(":0"))
 	require.NoError(t, err)
-	require.NotNil(t, shutdownFn)
+	require.NotNil(t, srv)
 
 	// First shutdown should succeed
 	ctx1, cancel1 := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel1()
-	err1 := shutdownFn(ctx1)
+	err1 := srv.Shutdown(ctx1)
 	assert.NoError(t, err1)
 
 	// Second shutdown should return nil (idempotent)
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel2()
-	err2 := shutdownFn(ctx2)
+	err2 := srv.Shutdown(ctx2)
 	assert.NoError(t, err2) // Should not error due to sync.Once
 }
 
@@ -463,7 +463,8 @@ func TestCreateServerHelperFunction(t *testing.T) {
 		WithHTTP2Config(customHTTP2),
 	}
 
-	server, opts := createServer(handler, options)
+	server, opts, err := createServer(handler, options)
+	require.NoError(t, err)
 
 	assert.Equal(t, ":8888", server.Addr)
 	assert.Equal(t, 1*time.Second, server.ReadTimeout)
@@ -514,13 +515,13 @@ func BenchmarkStartServer(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		shutdownFn, err := StartServer(handler, WithAddr(":0"))
+		srv, err := StartServer(handler, WithAddr(":0"))
 		if err != nil {
 			b.Fatal(err)
 		}
 
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
-		_ = shutdownFn(ctx)
+		_ = srv.Shutdown(ctx)
 		cancel()
 	}
 }
@@ -7,6 +7,45 @@ import (
 	"connectrpc.com/connect"
 )
 
+// SignatureErrorReason is a low-cardinality category of a signature
+// verification failure, suitable for use as a metric label.
+type SignatureErrorReason string
+
+const (
+	SignatureErrorReasonMissingHeader         SignatureErrorReason = "missing_header"
+	SignatureErrorReasonInvalidHeaderEncoding SignatureErrorReason = "invalid_header_encoding"
+	SignatureErrorReasonInvalidTimestamp      SignatureErrorReason = "invalid_timestamp"
+	SignatureErrorReasonTimestampOutOfWindow  SignatureErrorReason = "timestamp_out_of_window"
+	SignatureErrorReasonBodyTooLarge          SignatureErrorReason = "body_too_large"
+	SignatureErrorReasonUnknownPublicKey      SignatureErrorReason = "unknown_public_key"
+	SignatureErrorReasonInvalidSignature      SignatureErrorReason = "invalid_signature"
+	SignatureErrorReasonVerificationFailed    SignatureErrorReason = "verification_failed"
+)
+
+// signatureErrorReason maps an error returned during signature verification
+// to its reason. Errors not originating from this package fall back to
+// SignatureErrorReasonVerificationFailed.
+func signatureErrorReason(err error) SignatureErrorReason {
+	switch {
+	case errors.Is(err, ErrMissingRequiredHeader):
+		return SignatureErrorReasonMissingHeader
+	case errors.Is(err, ErrInvalidHeaderEncoding):
+		return SignatureErrorReasonInvalidHeaderEncoding
+	case errors.Is(err, ErrInvalidTimestampHeader):
+		return SignatureErrorReasonInvalidTimestamp
+	case errors.Is(err, ErrTimestampOutsideWindow):
+		return SignatureErrorReasonTimestampOutOfWindow
+	case errors.Is(err, ErrMaxPayloadSizeExceeded):
+		return SignatureErrorReasonBodyTooLarge
+	case errors.Is(err, ErrUnknownPublicKey):
+		return SignatureErrorReasonUnknownPublicKey
+	case errors.Is(err, ErrInvalidSignature):
+		return SignatureErrorReasonInvalidSignature
+	default:
+		return SignatureErrorReasonVerificationFailed
+	}
+}
+
 // signatureErrorInterceptor checks for a signature error in the context.
 // this error is propagated from the signature verification middleware.
 func signatureErrorInterceptor() connect.UnaryInterceptorFunc {
@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+const instrumentationName = "github.com/t-0-network/provider-sdk-go/provider"
+
+// signatureErrorReasonKey is the attribute carrying the SignatureErrorReason
+// of a failed signature verification.
+const signatureErrorReasonKey = attribute.Key("tzero.signature.error_reason")
+
+type telemetryOptions struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// handlerTelemetry instruments a provider handler with OpenTelemetry spans
+// and metrics.
+type handlerTelemetry struct {
+	tracer               trace.Tracer
+	propagator           propagation.TextMapPropagator
+	rpcDuration          metric.Float64Histogram
+	verificationDuration metric.Float64Histogram
+	verificationFailures metric.Int64Counter
+}
+
+// newHandlerTelemetry returns nil if neither a tracer provider nor a meter
+// provider is configured.
+func newHandlerTelemetry(opts telemetryOptions) *handlerTelemetry {
+	if opts.tracerProvider == nil && opts.meterProvider == nil {
+		return nil
+	}
+
+	tracerProvider := opts.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = tracenoop.NewTracerProvider()
+	}
+	meterProvider := opts.meterProvider
+	if meterProvider == nil {
+		meterProvider = metricnoop.NewMeterProvider()
+	}
+	propagator := opts.propagator
+	if propagator == nil {
+		propagator = propagation.TraceContext{}
+	}
+
+	meter := meterProvider.Meter(instrumentationName)
+	t := &handlerTelemetry{
+		tracer:     tracerProvider.Tracer(instrumentationName),
+		propagator: propagator,
+	}
+
+	var err error
+	t.rpcDuration, err = meter.Float64Histogram("tzero.provider.rpc.duration",
+		metric.WithDescription("Duration of provider RPCs handled by the SDK."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	t.verificationDuration, err = meter.Float64Histogram("tzero.provider.signature.verification.duration",
+		metric.WithDescription("Duration of request signature verification."),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	t.verificationFailures, err = meter.Int64Counter("tzero.provider.signature.verification.failures",
+		metric.WithDescription("Number of requests that failed signature verification, by reason."),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return t
+}
+
+// middleware extracts the W3C trace context from the request headers and
+// starts a server span covering the whole RPC, signature verification included.
+func (t *handlerTelemetry) middleware() middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := t.tracer.Start(ctx, strings.TrimPrefix(r.URL.Path, "/"),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(telemetry.ProcedureAttributes(r.URL.Path)...),
+			)
+			defer span.End()
+
+			ctx = withRequestObserver(ctx, t)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// interceptor records the outcome of the RPC on the server span and the
+// duration histogram. It must be installed as the outermost interceptor to
+// observe signature errors as well.
+func (t *handlerTelemetry) interceptor() connect.UnaryInterceptorFunc {
+	return func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			startedAt := time.Now()
+			res, err := next(ctx, req)
+
+			attrs := telemetry.ProcedureAttributes(req.Spec().Procedure)
+			if err != nil {
+				attrs = append(attrs, semconv.RPCConnectRPCErrorCodeKey.String(connect.CodeOf(err).String()))
+
+				span := trace.SpanFromContext(ctx)
+				span.SetAttributes(semconv.RPCConnectRPCErrorCodeKey.String(connect.CodeOf(err).String()))
+				span.SetStatus(codes.Error, err.Error())
+			}
+			t.rpcDuration.Record(ctx, time.Since(startedAt).Seconds(), metric.WithAttributes(attrs...))
+
+			return res, err
+		}
+	}
+}
+
+func (t *handlerTelemetry) observeSignatureVerification(
+	ctx context.Context, procedure string, sigErr *SignatureError, elapsed time.Duration,
+) {
+	attrs := telemetry.ProcedureAttributes(procedure)
+	t.verificationDuration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(attrs...))
+
+	if sigErr != nil {
+		attrs = append(attrs, signatureErrorReasonKey.String(string(sigErr.Reason)))
+		t.verificationFailures.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+}
+
+// startSignatureVerificationSpan starts a child span of the span found in ctx,
+// if any. Without a parent span the returned span is a no-op.
+func startSignatureVerificationSpan(ctx context.Context) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationName)
+	return tracer.Start(ctx, "VerifySignature", trace.WithSpanKind(trace.SpanKindInternal))
+}
+
+func endSignatureVerificationSpan(span trace.Span, sigErr *SignatureError) {
+	if sigErr != nil {
+		span.SetAttributes(signatureErrorReasonKey.String(string(sigErr.Reason)))
+		span.SetStatus(codes.Error, sigErr.Message)
+	}
+	span.End()
+}
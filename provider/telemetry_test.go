@@ -0,0 +1,159 @@
+package provider
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/crypto"
+	"github.com/t-0-network/provider-sdk-go/network"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type updateLimitProvider struct {
+	paymentconnect.UnimplementedProviderServiceHandler
+}
+
+func (updateLimitProvider) UpdateLimit(
+	context.Context, *connect.Request[payment.UpdateLimitRequest],
+) (*connect.Response[payment.UpdateLimitResponse], error) {
+	return connect.NewResponse(&payment.UpdateLimitResponse{}), nil
+}
+
+// newTestNetworkKey generates a key pair acting as the T-ZERO Network key.
+func newTestNetworkKey(t *testing.T) (network.PrivateKeyHexed, NetworkPublicKeyHexed) {
+	t.Helper()
+
+	privateKey, err := secp256k1.GeneratePrivateKey()
+	require.NoError(t, err)
+
+	return network.PrivateKeyHexed(crypto.HexPrivateKey(privateKey)),
+		NetworkPublicKeyHexed(crypto.HexPublicKey(privateKey.PubKey()))
+}
+
+func TestTelemetry_TracesPropagateFromNetworkClient(t *testing.T) {
+	privateKey, publicKey := newTestNetworkKey(t)
+
+	serverSpans := tracetest.NewInMemoryExporter()
+	serverTracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(serverSpans))
+	clientSpans := tracetest.NewInMemoryExporter()
+	clientTracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(clientSpans))
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(updateLimitProvider{}),
+			WithTracerProvider(serverTracerProvider),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client, err := network.NewServiceClient(privateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+		network.WithTracerProvider(clientTracerProvider),
+	)
+	require.NoError(t, err)
+
+	_, err = client.UpdateLimit(context.Background(), connect.NewRequest(&payment.UpdateLimitRequest{}))
+	require.NoError(t, err)
+
+	clientSpanByName := spansByName(clientSpans.GetSpans())
+	require.Contains(t, clientSpanByName, "tzero.v1.payment.ProviderService/UpdateLimit")
+	require.Contains(t, clientSpanByName, "SignRequest")
+	clientSpan := clientSpanByName["tzero.v1.payment.ProviderService/UpdateLimit"]
+	require.Equal(t, trace.SpanKindClient, clientSpan.SpanKind)
+	require.Equal(t, clientSpan.SpanContext.SpanID(), clientSpanByName["SignRequest"].Parent.SpanID())
+
+	serverSpanByName := spansByName(serverSpans.GetSpans())
+	require.Contains(t, serverSpanByName, "tzero.v1.payment.ProviderService/UpdateLimit")
+	require.Contains(t, serverSpanByName, "VerifySignature")
+	serverSpan := serverSpanByName["tzero.v1.payment.ProviderService/UpdateLimit"]
+	require.Equal(t, trace.SpanKindServer, serverSpan.SpanKind)
+	require.Equal(t, clientSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID())
+	require.Equal(t, clientSpan.SpanContext.SpanID(), serverSpan.Parent.SpanID())
+	require.Equal(t, serverSpan.SpanContext.SpanID(), serverSpanByName["VerifySignature"].Parent.SpanID())
+}
+
+func TestTelemetry_SignatureFailureMetrics(t *testing.T) {
+	_, publicKey := newTestNetworkKey(t)
+	otherPrivateKey, _ := newTestNetworkKey(t)
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	spans := tracetest.NewInMemoryExporter()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+
+	handler, err := NewHttpHandler(publicKey,
+		Handler(paymentconnect.NewProviderServiceHandler, paymentconnect.ProviderServiceHandler(updateLimitProvider{}),
+			WithTracerProvider(tracerProvider),
+			WithMeterProvider(meterProvider),
+		),
+	)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// Signed with a key the provider does not trust
+	client, err := network.NewServiceClient(otherPrivateKey, paymentconnect.NewProviderServiceClient,
+		network.WithBaseURL(server.URL),
+	)
+	require.NoError(t, err)
+
+	_, err = client.UpdateLimit(context.Background(), connect.NewRequest(&payment.UpdateLimitRequest{}))
+	require.Equal(t, connect.CodeUnauthenticated, connect.CodeOf(err))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	failures := findMetric(t, rm, "tzero.provider.signature.verification.failures")
+	sum, ok := failures.Data.(metricdata.Sum[int64])
+	require.True(t, ok)
+	require.Len(t, sum.DataPoints, 1)
+	require.Equal(t, int64(1), sum.DataPoints[0].Value)
+	reason, ok := sum.DataPoints[0].Attributes.Value(signatureErrorReasonKey)
+	require.True(t, ok)
+	require.Equal(t, string(SignatureErrorReasonUnknownPublicKey), reason.AsString())
+
+	rpcDuration := findMetric(t, rm, "tzero.provider.rpc.duration")
+	histogram, ok := rpcDuration.Data.(metricdata.Histogram[float64])
+	require.True(t, ok)
+	require.Len(t, histogram.DataPoints, 1)
+	require.Equal(t, uint64(1), histogram.DataPoints[0].Count)
+
+	verifySpan := spansByName(spans.GetSpans())["VerifySignature"]
+	require.Equal(t, "Error", verifySpan.Status.Code.String())
+}
+
+func spansByName(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+	return byName
+}
+
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	t.Helper()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+
+	require.Failf(t, "metric not found", "metric %q was not recorded", name)
+	return metricdata.Metrics{}
+}
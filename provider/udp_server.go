@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+// UDPServer serves the handler of a server started by StartServer over UDP,
+// alongside the TCP server, like the HTTP/3 server of the http3 package.
+type UDPServer interface {
+	// Serve serves the connection until the server is closed.
+	Serve(conn net.PacketConn) error
+	// SetHeaders announces the server in the headers of the responses sent
+	// over TCP, e.g. with Alt-Svc. It may fail until the server is serving.
+	SetHeaders(header http.Header) error
+	// Shutdown gracefully shuts down the server.
+	Shutdown(ctx context.Context) error
+	// Close closes the server immediately.
+	Close() error
+}
+
+// NewUDPServer returns the UDPServer serving the handler, with the TLS config
+// of the server, nil if TLS is not configured.
+type NewUDPServer func(handler http.Handler, tlsConfig *tls.Config) (UDPServer, error)
+
+// WithUDPServer also serves the handler over UDP, on the port with the same
+// number as the TCP port of the server, which must then be a TCP address.
+// Requests go through the same handler, including signature verification and
+// client certificate authentication. WithAllowedCIDRs applies to UDP packets,
+// the connection limits and the PROXY protocol only apply to TCP.
+//
+// See the http3 package for HTTP/3. StartServer serves the UDP server,
+// BuildServer fails with this option.
+func WithUDPServer(newServer NewUDPServer) ServerOption {
+	return func(opts *serverOptions) {
+		opts.newUDPServer = newServer
+	}
+}
+
+// announceMiddleware announces the UDP server in responses.
+func announceMiddleware(server UDPServer) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Fails until the server is listening, there is nothing to announce then
+			_ = server.SetHeaders(w.Header())
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// listenUDP listens on the UDP port matching the TCP address.
+func listenUDP(tcpAddr net.Addr, allowedCIDRs []netip.Prefix) (net.PacketConn, error) {
+	addr, ok := tcpAddr.(*net.TCPAddr)
+	if !ok {
+		return nil, errors.New("the UDP server requires a TCP listener")
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone})
+	if err != nil {
+		return nil, err
+	}
+	if len(allowedCIDRs) > 0 {
+		return &allowlistPacketConn{PacketConn: conn, udpConn: conn, allowedCIDRs: allowedCIDRs}, nil
+	}
+	return conn, nil
+}
+
+// allowlistPacketConn drops the packets sent from outside the allowed
+// networks. It hides the methods of *net.UDPConn reading packets, so that
+// the UDP server only reads them through ReadFrom.
+type allowlistPacketConn struct {
+	net.PacketConn
+	udpConn      *net.UDPConn
+	allowedCIDRs []netip.Prefix
+}
+
+func (c *allowlistPacketConn) SetReadBuffer(bytes int) error {
+	return c.udpConn.SetReadBuffer(bytes)
+}
+
+func (c *allowlistPacketConn) SetWriteBuffer(bytes int) error {
+	return c.udpConn.SetWriteBuffer(bytes)
+}
+
+func (c *allowlistPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil {
+			return n, addr, err
+		}
+		if udpAddr, ok := addr.(*net.UDPAddr); ok && containsIP(c.allowedCIDRs, udpAddr.AddrPort().Addr().Unmap()) {
+			return n, addr, nil
+		}
+	}
+}
@@ -23,6 +23,7 @@ type middleware func(http.Handler) http.Handler
 type SignatureError struct {
 	ConnectCode connect.Code
 	Message     string
+	Reason      SignatureErrorReason
 }
 
 type signatureErrorContextKey struct{}
@@ -32,47 +33,66 @@ func getSignatureErrorFromContext(ctx context.Context) (*SignatureError, bool) {
 	return sigErr, ok
 }
 
+// newSignatureVerifierMiddleware verifies the request signature, capping the
+// body size by the procedure override if any, or by maxBodySizeOpt otherwise.
 func newSignatureVerifierMiddleware(
 	verifySignature VerifySignature,
 	maxBodySizeOpt int64,
+	maxBodySizeByProcedure map[string]int64,
 ) middleware {
 	return func(handler http.Handler) http.Handler {
 		return http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
-			setErrorAndContinue := func(req *http.Request, statusCode connect.Code, message string) {
-				errObj := &SignatureError{
-					ConnectCode: statusCode,
-					Message:     message,
-				}
-				ctx := context.WithValue(req.Context(), signatureErrorContextKey{}, errObj)
+			startedAt := time.Now()
+			_, span := startSignatureVerificationSpan(req.Context())
+
+			finish := func(req *http.Request, sigErr *SignatureError) {
+				endSignatureVerificationSpan(span, sigErr)
+				notifySignatureObservers(req.Context(), req.URL.Path, sigErr, time.Since(startedAt))
+
+				ctx := context.WithValue(req.Context(), signatureErrorContextKey{}, sigErr)
+				ctx = withClientCertificate(ctx, req)
 				handler.ServeHTTP(writer, req.WithContext(ctx))
 			}
 
+			setErrorAndContinue := func(req *http.Request, statusCode connect.Code, err error) {
+				finish(req, &SignatureError{
+					ConnectCode: statusCode,
+					Message:     err.Error(),
+					Reason:      signatureErrorReason(err),
+				})
+			}
+
 			publicKey, err := parseRequiredHexedHeader(common.PublicKeyHeader, req.Header)
 			if err != nil {
-				setErrorAndContinue(req, connect.CodeInvalidArgument, err.Error())
+				setErrorAndContinue(req, connect.CodeInvalidArgument, err)
 				return
 			}
 
 			signature, err := parseRequiredHexedHeader(common.SignatureHeader, req.Header)
 			if err != nil {
-				setErrorAndContinue(req, connect.CodeInvalidArgument, err.Error())
+				setErrorAndContinue(req, connect.CodeInvalidArgument, err)
 				return
 			}
 
 			timestamp, timestampBytes, err := parseTimestamp(req.Header)
 			if err != nil {
-				setErrorAndContinue(req, connect.CodeInvalidArgument, err.Error())
+				setErrorAndContinue(req, connect.CodeInvalidArgument, err)
 				return
 			}
 
 			if !timesWithinDelta(timestamp, time.Now(), time.Minute) {
-				setErrorAndContinue(req, connect.CodeInvalidArgument, "timestamp is outside the allowed time window")
+				setErrorAndContinue(req, connect.CodeInvalidArgument, ErrTimestampOutsideWindow)
 				return
 			}
 
-			body, err := readBodyWithCap(req, maxBodySizeOpt)
+			maxBodySize, ok := maxBodySizeByProcedure[req.URL.Path]
+			if !ok {
+				maxBodySize = maxBodySizeOpt
+			}
+
+			body, err := readBodyWithCap(req, maxBodySize)
 			if err != nil {
-				setErrorAndContinue(req, connect.CodeInvalidArgument, err.Error())
+				setErrorAndContinue(req, connect.CodeInvalidArgument, err)
 				return
 			}
 
@@ -81,12 +101,11 @@ func newSignatureVerifierMiddleware(
 			req.Body = io.NopCloser(bytes.NewReader(body))
 
 			if err := verifySignature(publicKey, append(body, timestampBytes[:]...), signature); err != nil {
-				setErrorAndContinue(req, connect.CodeUnauthenticated, err.Error())
+				setErrorAndContinue(req, connect.CodeUnauthenticated, err)
 				return
 			}
 
-			ctx := context.WithValue(req.Context(), signatureErrorContextKey{}, (*SignatureError)(nil))
-			handler.ServeHTTP(writer, req.WithContext(ctx))
+			finish(req, nil)
 		})
 	}
 }
@@ -121,7 +140,7 @@ func parseTimestamp(headers http.Header) (time.Time, [8]byte, error) {
 
 	timestamp, err := strconv.ParseInt(timestampValue, 10, 64)
 	if err != nil {
-		return time.Time{}, tsBytes, fmt.Errorf("invalid timestamp header: %s", err.Error())
+		return time.Time{}, tsBytes, fmt.Errorf("%w: %s", ErrInvalidTimestampHeader, err.Error())
 	}
 
 	binary.LittleEndian.PutUint64(tsBytes[:], uint64(timestamp))
@@ -132,7 +151,7 @@ func readBodyWithCap(r *http.Request, cap int64) ([]byte, error) {
 	contentLenHeader := r.Header.Get("Content-Length")
 	contentLen, err := strconv.ParseInt(contentLenHeader, 10, 64)
 	if err == nil && contentLen > cap {
-		return nil, fmt.Errorf("max payload size of %d bytes exceeded", cap)
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrMaxPayloadSizeExceeded, cap)
 	}
 	// The Content-Length header is optional, and we shouldn't trust it anyway. It's just an optimization.
 	// Let's also put a cap while reading the body to avoid memory overload.
@@ -140,7 +159,7 @@ func readBodyWithCap(r *http.Request, cap int64) ([]byte, error) {
 	w := bufio.NewWriter(&body)
 	_, err = io.CopyN(w, r.Body, cap)
 	if err == nil || !errors.Is(err, io.EOF) {
-		return nil, fmt.Errorf("max payload size of %d bytes exceeded", cap)
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrMaxPayloadSizeExceeded, cap)
 	}
 
 	return body.Bytes(), nil
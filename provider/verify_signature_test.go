@@ -214,7 +214,7 @@ func TestNewSignatureVerifierMiddleware(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create middleware
-			middleware := newSignatureVerifierMiddleware(tt.verifySignatureFunc, 1024*1024)
+			middleware := newSignatureVerifierMiddleware(tt.verifySignatureFunc, 1024*1024, nil)
 
 			// Create test handler that checks for signature errors
 			var capturedError *SignatureError
@@ -0,0 +1,169 @@
+package quotes
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/common"
+	"google.golang.org/protobuf/proto"
+)
+
+// Side tells whether the quotes of a market are pay-out or pay-in quotes.
+type Side int
+
+const (
+	// PayOut quotes are the rates at which the provider pays out.
+	PayOut Side = iota
+	// PayIn quotes are the rates at which the provider collects payments.
+	PayIn
+)
+
+func (s Side) String() string {
+	switch s {
+	case PayOut:
+		return "pay-out"
+	case PayIn:
+		return "pay-in"
+	default:
+		return fmt.Sprintf("Side(%d)", int(s))
+	}
+}
+
+// Rate is a USD/currency rate returned by a RateSource.
+type Rate struct {
+	// Value is the USD/currency rate.
+	Value *common.Decimal
+	// Time is when the rate was observed. The rate is stale once it is older
+	// than the maximum rate age, see WithMaxRateAge. A zero time means the
+	// rate was observed when it was returned.
+	Time time.Time
+}
+
+// RateSource provides the rates of the markets, e.g. from a market data feed
+// or a treasury system.
+type RateSource interface {
+	// Rate returns the current USD/currency rate for the given currency and
+	// payment method.
+	Rate(ctx context.Context, currency string, method common.PaymentMethodType) (Rate, error)
+}
+
+// RateSourceFunc is an adapter to use a function as a RateSource.
+type RateSourceFunc func(ctx context.Context, currency string, method common.PaymentMethodType) (Rate, error)
+
+func (f RateSourceFunc) Rate(ctx context.Context, currency string, method common.PaymentMethodType) (Rate, error) {
+	return f(ctx, currency, method)
+}
+
+// Band defines a pricing band of a market.
+type Band struct {
+	// MaxAmount is the maximum USD amount the band applies to.
+	MaxAmount *common.Decimal
+	// SpreadBps adjusts the rate of the source for the band, in basis points:
+	// the band rate is rate * (1 + SpreadBps/10000).
+	SpreadBps int64
+}
+
+// Market is a currency and payment method the provider publishes quotes for.
+type Market struct {
+	// Side of the quotes. It is ignored by the payment intent publisher,
+	// whose quotes are pay-in quotes.
+	Side Side
+	// Currency is the ISO 4217 currency code, e.g. EUR.
+	Currency string
+	// PaymentMethod of the quotes.
+	PaymentMethod common.PaymentMethodType
+	// Source provides the rates of the market.
+	Source RateSource
+	// Bands of the quotes, at least one.
+	Bands []Band
+}
+
+func (m Market) validate() error {
+	if m.Currency == "" {
+		return fmt.Errorf("%w: currency is not set", ErrInvalidMarket)
+	}
+	if m.Source == nil {
+		return fmt.Errorf("%w: %s has no rate source", ErrInvalidMarket, m.key())
+	}
+	if len(m.Bands) == 0 {
+		return fmt.Errorf("%w: %s has no bands", ErrInvalidMarket, m.key())
+	}
+	for i, band := range m.Bands {
+		if band.MaxAmount == nil {
+			return fmt.Errorf("%w: band %d of %s has no max amount", ErrInvalidMarket, i, m.key())
+		}
+		if band.SpreadBps <= -10000 {
+			return fmt.Errorf("%w: band %d of %s has a spread of %d bps", ErrInvalidMarket, i, m.key(), band.SpreadBps)
+		}
+	}
+	return nil
+}
+
+// key identifies the market, which must be unique within a publisher.
+func (m Market) key() string {
+	return marketKey(m.Side, m.Currency, m.PaymentMethod)
+}
+
+func marketKey(side Side, currency string, method common.PaymentMethodType) string {
+	return fmt.Sprintf("%s %s %s", side, currency, method)
+}
+
+// Quote is a published quote, as reported to the hook of WithPublishHook.
+type Quote struct {
+	Side          Side
+	Currency      string
+	PaymentMethod common.PaymentMethodType
+	Bands         []QuoteBand
+	Timestamp     time.Time
+	Expiration    time.Time
+}
+
+// QuoteBand is a band of a published quote.
+type QuoteBand struct {
+	// ClientQuoteID identifies the band, it is referenced by the payments
+	// using the quote.
+	ClientQuoteID string
+	MaxAmount     *common.Decimal
+	Rate          *common.Decimal
+}
+
+// sameRates tells whether two quotes of a market have the same band rates.
+func sameRates(a, b Quote) bool {
+	if len(a.Bands) != len(b.Bands) {
+		return false
+	}
+	for i := range a.Bands {
+		if !proto.Equal(a.Bands[i].Rate, b.Bands[i].Rate) {
+			return false
+		}
+	}
+	return true
+}
+
+// applySpread returns rate * (1 + bps/10000), without rounding.
+func applySpread(rate *common.Decimal, bps int64) (*common.Decimal, error) {
+	if bps == 0 {
+		return proto.Clone(rate).(*common.Decimal), nil
+	}
+
+	unscaled := new(big.Int).Mul(big.NewInt(rate.GetUnscaled()), big.NewInt(10000+bps))
+	exponent := rate.GetExponent() - 4
+
+	// Drop the trailing zeros added by the multiplication
+	ten := big.NewInt(10)
+	for exponent < rate.GetExponent() && unscaled.Sign() != 0 {
+		quotient, remainder := new(big.Int).QuoRem(unscaled, ten, new(big.Int))
+		if remainder.Sign() != 0 {
+			break
+		}
+		unscaled = quotient
+		exponent++
+	}
+
+	if !unscaled.IsInt64() {
+		return nil, fmt.Errorf("%w: spread of %d bps", ErrRateOverflow, bps)
+	}
+	return &common.Decimal{Unscaled: unscaled.Int64(), Exponent: exponent}, nil
+}
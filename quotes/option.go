@@ -0,0 +1,138 @@
+package quotes
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Default values of the publisher options
+const (
+	DefaultRefreshInterval = 5 * time.Second
+	DefaultQuoteTTL        = time.Minute
+	DefaultRefreshAhead    = 20 * time.Second
+	DefaultMaxRateAge      = 30 * time.Second
+	DefaultMinInterval     = 500 * time.Millisecond
+	DefaultRetryBackoff    = time.Second
+)
+
+var (
+	ErrNoMarkets        = errors.New("no markets to publish quotes for")
+	ErrInvalidMarket    = errors.New("invalid market")
+	ErrDuplicateMarket  = errors.New("duplicate market")
+	ErrInvalidSchedule  = errors.New("invalid publishing schedule")
+	ErrRateOverflow     = errors.New("band rate overflows the decimal")
+	ErrStaleRate        = errors.New("rate is stale")
+	ErrPublisherStopped = errors.New("publisher is stopped")
+)
+
+// Option configures a Publisher.
+type Option func(*options)
+
+type options struct {
+	refreshInterval time.Duration
+	quoteTTL        time.Duration
+	refreshAhead    time.Duration
+	maxRateAge      time.Duration
+	minInterval     time.Duration
+	retryBackoff    time.Duration
+	publishHook     func(ctx context.Context, quotes []Quote)
+	errorHook       func(ctx context.Context, err error)
+}
+
+func defaultOptions() *options {
+	return &options{
+		refreshInterval: DefaultRefreshInterval,
+		quoteTTL:        DefaultQuoteTTL,
+		refreshAhead:    DefaultRefreshAhead,
+		maxRateAge:      DefaultMaxRateAge,
+		minInterval:     DefaultMinInterval,
+		retryBackoff:    DefaultRetryBackoff,
+		errorHook:       defaultErrorHook,
+	}
+}
+
+func (o *options) validate() error {
+	if o.refreshInterval <= 0 || o.quoteTTL <= 0 || o.maxRateAge <= 0 || o.retryBackoff <= 0 {
+		return ErrInvalidSchedule
+	}
+	if o.refreshAhead <= 0 || o.refreshAhead >= o.quoteTTL {
+		return errors.Join(ErrInvalidSchedule, errors.New("refresh ahead must be between 0 and the quote TTL"))
+	}
+	return nil
+}
+
+// defaultErrorHook logs the error using the default slog logger.
+func defaultErrorHook(ctx context.Context, err error) {
+	slog.WarnContext(ctx, "quote publisher failed", slog.Any("error", err))
+}
+
+// WithRefreshInterval sets how often the rates are fetched. Quotes are only
+// published when a rate changed, or ahead of their expiration.
+// Defaults to DefaultRefreshInterval.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.refreshInterval = interval
+	}
+}
+
+// WithQuoteTTL sets the validity of the published quotes, from their
+// publication to their expiration. Defaults to DefaultQuoteTTL.
+func WithQuoteTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.quoteTTL = ttl
+	}
+}
+
+// WithRefreshAhead sets how long before their expiration the quotes are
+// published again, even if the rates did not change. It must be shorter
+// than the quote TTL. Defaults to DefaultRefreshAhead.
+func WithRefreshAhead(ahead time.Duration) Option {
+	return func(o *options) {
+		o.refreshAhead = ahead
+	}
+}
+
+// WithMaxRateAge sets the age after which a rate is stale. The quotes of a
+// market with a stale rate are no longer published, and expire.
+// Defaults to DefaultMaxRateAge.
+func WithMaxRateAge(age time.Duration) Option {
+	return func(o *options) {
+		o.maxRateAge = age
+	}
+}
+
+// WithMinInterval sets the minimum interval between two refreshes requested
+// with Publisher.Refresh. Requests within the interval are coalesced into a
+// single publication. Defaults to DefaultMinInterval.
+func WithMinInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.minInterval = interval
+	}
+}
+
+// WithRetryBackoff sets the delay before retrying a failed publication. It
+// doubles after each failure, up to the refresh interval.
+// Defaults to DefaultRetryBackoff.
+func WithRetryBackoff(backoff time.Duration) Option {
+	return func(o *options) {
+		o.retryBackoff = backoff
+	}
+}
+
+// WithPublishHook registers a hook called with the quotes of every successful
+// publication, for instance to record the client quote IDs.
+func WithPublishHook(hook func(ctx context.Context, quotes []Quote)) Option {
+	return func(o *options) {
+		o.publishHook = hook
+	}
+}
+
+// WithErrorHook registers a hook called with the rate source and publication
+// errors. Defaults to logging with the default slog logger.
+func WithErrorHook(hook func(ctx context.Context, err error)) Option {
+	return func(o *options) {
+		o.errorHook = hook
+	}
+}
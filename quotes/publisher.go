@@ -0,0 +1,384 @@
+package quotes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// publishFn sends the quotes to the network.
+type publishFn func(ctx context.Context, quotes []Quote) error
+
+// Publisher keeps the quotes of its markets published in the network. It
+// fetches the rates on a schedule and publishes them, as a single request,
+// when one of them changed or ahead of the expiration of the published
+// quotes. Failed publications are retried with a backoff. The quotes of a
+// market whose rate is stale are no longer published, so they expire.
+type Publisher struct {
+	markets []Market
+	opts    *options
+	publish publishFn
+
+	refresh chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// Owned by the publishing goroutine
+	rates      []Rate
+	stale      []bool
+	published  map[string]Quote
+	expiration time.Time
+	failed     bool
+	backoff    time.Duration
+	lastTick   time.Time
+}
+
+// NewPaymentPublisher creates a publisher of pay-out and pay-in quotes,
+// published with payment.NetworkService.UpdateQuote.
+//
+// Example:
+//
+//	publisher, err := quotes.NewPaymentPublisher(client.Payment, []quotes.Market{{
+//	    Side:          quotes.PayOut,
+//	    Currency:      "EUR",
+//	    PaymentMethod: common.PaymentMethodType_PAYMENT_METHOD_TYPE_SEPA,
+//	    Source:        eurRates,
+//	    Bands: []quotes.Band{
+//	        {MaxAmount: &common.Decimal{Unscaled: 1000}, SpreadBps: 50},
+//	        {MaxAmount: &common.Decimal{Unscaled: 10000}, SpreadBps: 20},
+//	    },
+//	}})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	if err := publisher.Start(); err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer publisher.Stop(context.Background())
+func NewPaymentPublisher(client paymentconnect.NetworkServiceClient, markets []Market, opts ...Option) (*Publisher, error) {
+	return newPublisher(markets, func(ctx context.Context, quotes []Quote) error {
+		req := &payment.UpdateQuoteRequest{}
+		for _, quote := range quotes {
+			q := &payment.UpdateQuoteRequest_Quote{
+				Currency:      quote.Currency,
+				QuoteType:     payment.QuoteType_QUOTE_TYPE_REALTIME,
+				PaymentMethod: quote.PaymentMethod,
+				Expiration:    timestamppb.New(quote.Expiration),
+				Timestamp:     timestamppb.New(quote.Timestamp),
+			}
+			for _, band := range quote.Bands {
+				q.Bands = append(q.Bands, &payment.UpdateQuoteRequest_Quote_Band{
+					ClientQuoteId: band.ClientQuoteID,
+					MaxAmount:     band.MaxAmount,
+					Rate:          band.Rate,
+				})
+			}
+			if quote.Side == PayIn {
+				req.PayIn = append(req.PayIn, q)
+			} else {
+				req.PayOut = append(req.PayOut, q)
+			}
+		}
+		_, err := client.UpdateQuote(ctx, connect.NewRequest(req))
+		return err
+	}, opts)
+}
+
+// NewPaymentIntentPublisher creates a publisher of payment intent quotes,
+// published with payment_intent.PaymentIntentService.UpdateQuote. The Side
+// of the markets is ignored, all the quotes are pay-in quotes.
+func NewPaymentIntentPublisher(client payment_intentconnect.PaymentIntentServiceClient, markets []Market, opts ...Option) (*Publisher, error) {
+	markets = append([]Market(nil), markets...)
+	for i := range markets {
+		markets[i].Side = PayIn
+	}
+
+	return newPublisher(markets, func(ctx context.Context, quotes []Quote) error {
+		req := &payment_intent.UpdateQuoteRequest{}
+		for _, quote := range quotes {
+			q := &payment_intent.UpdateQuoteRequest_Quote{
+				Currency:      quote.Currency,
+				PaymentMethod: quote.PaymentMethod,
+				Expiration:    timestamppb.New(quote.Expiration),
+				Timestamp:     timestamppb.New(quote.Timestamp),
+			}
+			for _, band := range quote.Bands {
+				q.Bands = append(q.Bands, &payment_intent.UpdateQuoteRequest_Quote_Band{
+					ClientQuoteId: band.ClientQuoteID,
+					MaxAmount:     band.MaxAmount,
+					Rate:          band.Rate,
+				})
+			}
+			req.PaymentIntentQuotes = append(req.PaymentIntentQuotes, q)
+		}
+		_, err := client.UpdateQuote(ctx, connect.NewRequest(req))
+		return err
+	}, opts)
+}
+
+func newPublisher(markets []Market, publish publishFn, opts []Option) (*Publisher, error) {
+	options := defaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(markets) == 0 {
+		return nil, ErrNoMarkets
+	}
+	keys := make(map[string]bool, len(markets))
+	for _, market := range markets {
+		if err := market.validate(); err != nil {
+			return nil, err
+		}
+		if keys[market.key()] {
+			return nil, fmt.Errorf("%w: %s", ErrDuplicateMarket, market.key())
+		}
+		keys[market.key()] = true
+	}
+
+	return &Publisher{
+		markets: markets,
+		opts:    options,
+		publish: publish,
+		refresh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		rates:   make([]Rate, len(markets)),
+		stale:   make([]bool, len(markets)),
+		backoff: options.retryBackoff,
+	}, nil
+}
+
+// Start starts publishing in the background, until Stop is called. Calling it
+// again has no effect, and it fails with ErrPublisherStopped once the
+// publisher is stopped.
+func (p *Publisher) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stopped {
+		return ErrPublisherStopped
+	}
+	if p.started {
+		return nil
+	}
+	p.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.run(ctx)
+	return nil
+}
+
+// Stop stops publishing and waits for an in-flight publication, within the
+// given context. The published quotes are left to expire. It can be used as
+// a provider.ShutdownHook.
+func (p *Publisher) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	p.stopped = true
+	started := p.started
+	if started {
+		p.cancel()
+	}
+	p.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Refresh requests the rates to be fetched now, for instance when a rate
+// source is notified of a change. Requests are coalesced, and the rates are
+// fetched at most once per minimum interval, see WithMinInterval.
+func (p *Publisher) Refresh() {
+	select {
+	case p.refresh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Publisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	next := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.refresh:
+			wake := p.lastTick.Add(p.opts.minInterval)
+			if wake.Before(next) {
+				next = wake
+				timer.Reset(time.Until(wake))
+			}
+			continue
+		case <-timer.C:
+		}
+
+		next = p.tick(ctx)
+		timer.Reset(time.Until(next))
+	}
+}
+
+// tick fetches the rates, publishes the quotes if needed, and returns when
+// the next tick is due.
+func (p *Publisher) tick(ctx context.Context) time.Time {
+	now := time.Now()
+	p.lastTick = now
+	next := now.Add(p.opts.refreshInterval)
+
+	quotes := p.quotes(ctx, now)
+	if ctx.Err() != nil {
+		return next
+	}
+	if len(quotes) == 0 {
+		// Nothing left to publish, the published quotes expire
+		p.published, p.failed = nil, false
+		return next
+	}
+
+	refreshAt := p.expiration.Add(-p.opts.refreshAhead)
+	if p.failed || p.changed(quotes) || !now.Before(refreshAt) {
+		// Publishing expired quotes would be rejected
+		publishCtx, cancel := context.WithDeadline(ctx, quotes[0].Expiration)
+		err := p.publish(publishCtx, quotes)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return next
+			}
+			p.opts.errorHook(ctx, fmt.Errorf("publishing quotes: %w", err))
+			p.failed = true
+			retryAt := now.Add(p.backoff)
+			p.backoff = min(2*p.backoff, p.opts.refreshInterval)
+			return retryAt
+		}
+
+		p.failed = false
+		p.backoff = p.opts.retryBackoff
+		p.expiration = quotes[0].Expiration
+		p.published = make(map[string]Quote, len(quotes))
+		for i, quote := range quotes {
+			p.published[quoteKey(quote)] = quotes[i]
+		}
+		if p.opts.publishHook != nil {
+			p.opts.publishHook(ctx, quotes)
+		}
+		refreshAt = p.expiration.Add(-p.opts.refreshAhead)
+	}
+
+	if refreshAt.Before(next) {
+		next = refreshAt
+	}
+	return next
+}
+
+// quotes fetches the rates and returns the quotes of the markets whose rate
+// is not stale.
+func (p *Publisher) quotes(ctx context.Context, now time.Time) []Quote {
+	expiration := now.Add(p.opts.quoteTTL)
+
+	var quotes []Quote
+	for i, market := range p.markets {
+		rate, err := market.Source.Rate(ctx, market.Currency, market.PaymentMethod)
+		if ctx.Err() != nil {
+			return nil
+		}
+		switch {
+		case err != nil:
+			p.opts.errorHook(ctx, fmt.Errorf("fetching the rate of %s: %w", market.key(), err))
+		case rate.Value == nil:
+			p.opts.errorHook(ctx, fmt.Errorf("%w: the rate of %s is not set", ErrInvalidMarket, market.key()))
+		default:
+			if rate.Time.IsZero() {
+				rate.Time = now
+			}
+			p.rates[i] = rate
+		}
+
+		// The last rate is used until it gets stale
+		rate = p.rates[i]
+		stale := rate.Value == nil || now.Sub(rate.Time) > p.opts.maxRateAge
+		if stale && !p.stale[i] && rate.Value != nil {
+			p.opts.errorHook(ctx, fmt.Errorf("%w: %s, observed at %s", ErrStaleRate, market.key(), rate.Time.Format(time.RFC3339)))
+		}
+		p.stale[i] = stale
+		if stale {
+			continue
+		}
+
+		quote, err := market.quote(rate, now, expiration)
+		if err != nil {
+			p.opts.errorHook(ctx, err)
+			continue
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes
+}
+
+// changed tells whether the quotes differ from the published ones.
+func (p *Publisher) changed(quotes []Quote) bool {
+	if len(quotes) != len(p.published) {
+		return true
+	}
+	for _, quote := range quotes {
+		published, ok := p.published[quoteKey(quote)]
+		if !ok || !sameRates(quote, published) {
+			return true
+		}
+	}
+	return false
+}
+
+// quote builds the quote of the market for the given rate. The client quote
+// IDs identify the market, the band and the publication time.
+func (m Market) quote(rate Rate, now, expiration time.Time) (Quote, error) {
+	quote := Quote{
+		Side:          m.Side,
+		Currency:      m.Currency,
+		PaymentMethod: m.PaymentMethod,
+		Timestamp:     now,
+		Expiration:    expiration,
+	}
+	for i, band := range m.Bands {
+		bandRate, err := applySpread(rate.Value, band.SpreadBps)
+		if err != nil {
+			return Quote{}, fmt.Errorf("band %d of %s: %w", i, m.key(), err)
+		}
+		quote.Bands = append(quote.Bands, QuoteBand{
+			ClientQuoteID: fmt.Sprintf("%s-%s-%d-%d-%d", m.Currency, m.Side, int32(m.PaymentMethod), i, now.UnixMilli()),
+			MaxAmount:     band.MaxAmount,
+			Rate:          bandRate,
+		})
+	}
+	return quote, nil
+}
+
+func quoteKey(quote Quote) string {
+	return marketKey(quote.Side, quote.Currency, quote.PaymentMethod)
+}
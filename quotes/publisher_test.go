@@ -0,0 +1,336 @@
+package quotes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/common"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment/paymentconnect"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent"
+	"github.com/t-0-network/provider-sdk-go/api/tzero/v1/payment_intent/payment_intentconnect"
+	"google.golang.org/protobuf/proto"
+)
+
+// paymentNetwork records the published quotes, failing with the queued errors
+// first.
+type paymentNetwork struct {
+	paymentconnect.NetworkServiceClient
+
+	mu       sync.Mutex
+	errs     []error
+	requests []*payment.UpdateQuoteRequest
+}
+
+func (n *paymentNetwork) UpdateQuote(
+	_ context.Context, req *connect.Request[payment.UpdateQuoteRequest],
+) (*connect.Response[payment.UpdateQuoteResponse], error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.errs) > 0 {
+		err := n.errs[0]
+		n.errs = n.errs[1:]
+		return nil, err
+	}
+	n.requests = append(n.requests, req.Msg)
+	return connect.NewResponse(&payment.UpdateQuoteResponse{}), nil
+}
+
+func (n *paymentNetwork) published() []*payment.UpdateQuoteRequest {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]*payment.UpdateQuoteRequest(nil), n.requests...)
+}
+
+type paymentIntentNetwork struct {
+	payment_intentconnect.PaymentIntentServiceClient
+
+	requests chan *payment_intent.UpdateQuoteRequest
+}
+
+func (n *paymentIntentNetwork) UpdateQuote(
+	_ context.Context, req *connect.Request[payment_intent.UpdateQuoteRequest],
+) (*connect.Response[payment_intent.UpdateQuoteResponse], error) {
+	n.requests <- req.Msg
+	return connect.NewResponse(&payment_intent.UpdateQuoteResponse{}), nil
+}
+
+// rateSource returns the rate it is set to.
+type rateSource struct {
+	mu   sync.Mutex
+	rate Rate
+	err  error
+}
+
+func (s *rateSource) Rate(context.Context, string, common.PaymentMethodType) (Rate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate, s.err
+}
+
+func (s *rateSource) set(rate Rate, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rate, s.err = rate, err
+}
+
+func decimal(unscaled int64, exponent int32) *common.Decimal {
+	return &common.Decimal{Unscaled: unscaled, Exponent: exponent}
+}
+
+func assertDecimal(t *testing.T, expected, actual *common.Decimal) {
+	t.Helper()
+	assert.True(t, proto.Equal(expected, actual), "expected %v, got %v", expected, actual)
+}
+
+func eurMarket(source RateSource) Market {
+	return Market{
+		Currency:      "EUR",
+		PaymentMethod: common.PaymentMethodType_PAYMENT_METHOD_TYPE_SEPA,
+		Source:        source,
+		Bands: []Band{
+			{MaxAmount: decimal(1000, 0), SpreadBps: 50},
+			{MaxAmount: decimal(10000, 0)},
+		},
+	}
+}
+
+func startPublisher(t *testing.T, publisher *Publisher, err error) {
+	t.Helper()
+	require.NoError(t, err)
+	require.NoError(t, publisher.Start())
+	t.Cleanup(func() {
+		assert.NoError(t, publisher.Stop(context.Background()))
+	})
+}
+
+func TestPaymentPublisher_PublishesBands(t *testing.T) {
+	network := &paymentNetwork{}
+	source := RateSourceFunc(func(context.Context, string, common.PaymentMethodType) (Rate, error) {
+		return Rate{Value: decimal(92, -2)}, nil
+	})
+	payIn := eurMarket(source)
+	payIn.Side = PayIn
+
+	var hooked []Quote
+	publisher, err := NewPaymentPublisher(network, []Market{eurMarket(source), payIn},
+		WithQuoteTTL(time.Minute),
+		WithPublishHook(func(_ context.Context, quotes []Quote) { hooked = quotes }),
+	)
+	startPublisher(t, publisher, err)
+
+	require.Eventually(t, func() bool { return len(network.published()) == 1 }, time.Second, 5*time.Millisecond)
+	require.NoError(t, publisher.Stop(context.Background()))
+
+	req := network.published()[0]
+	require.Len(t, req.PayOut, 1)
+	require.Len(t, req.PayIn, 1)
+
+	quote := req.PayOut[0]
+	assert.Equal(t, "EUR", quote.Currency)
+	assert.Equal(t, payment.QuoteType_QUOTE_TYPE_REALTIME, quote.QuoteType)
+	assert.Equal(t, common.PaymentMethodType_PAYMENT_METHOD_TYPE_SEPA, quote.PaymentMethod)
+	assert.Equal(t, time.Minute, quote.Expiration.AsTime().Sub(quote.Timestamp.AsTime()))
+	require.Len(t, quote.Bands, 2)
+	assert.Equal(t, int64(1000), quote.Bands[0].MaxAmount.Unscaled)
+	assertDecimal(t, decimal(9246, -4), quote.Bands[0].Rate)
+	assertDecimal(t, decimal(92, -2), quote.Bands[1].Rate)
+
+	ids := map[string]bool{}
+	for _, q := range append(req.PayOut, req.PayIn...) {
+		for _, band := range q.Bands {
+			ids[band.ClientQuoteId] = true
+		}
+	}
+	assert.Len(t, ids, 4, "client quote IDs must be unique")
+
+	require.Len(t, hooked, 2)
+	assert.Equal(t, quote.Bands[0].ClientQuoteId, hooked[0].Bands[0].ClientQuoteID)
+}
+
+func TestPublisher_CoalescesUnchangedRates(t *testing.T) {
+	network := &paymentNetwork{}
+	source := &rateSource{rate: Rate{Value: decimal(92, -2)}}
+	publisher, err := NewPaymentPublisher(network, []Market{eurMarket(source)},
+		WithRefreshInterval(10*time.Millisecond),
+		WithMinInterval(time.Millisecond),
+	)
+	startPublisher(t, publisher, err)
+
+	// The rates are fetched again, but not published
+	time.Sleep(100 * time.Millisecond)
+	assert.Len(t, network.published(), 1)
+
+	source.set(Rate{Value: decimal(93, -2)}, nil)
+	for range 5 {
+		publisher.Refresh()
+	}
+	require.Eventually(t, func() bool { return len(network.published()) == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, network.published(), 2)
+	assert.Equal(t, int64(93), network.published()[1].PayOut[0].Bands[1].Rate.Unscaled)
+}
+
+func TestPublisher_RefreshesAheadOfExpiration(t *testing.T) {
+	network := &paymentNetwork{}
+	source := &rateSource{rate: Rate{Value: decimal(92, -2)}}
+	publisher, err := NewPaymentPublisher(network, []Market{eurMarket(source)},
+		WithRefreshInterval(time.Hour),
+		WithQuoteTTL(200*time.Millisecond),
+		WithRefreshAhead(150*time.Millisecond),
+	)
+	startPublisher(t, publisher, err)
+
+	require.Eventually(t, func() bool { return len(network.published()) >= 3 }, time.Second, 5*time.Millisecond)
+
+	requests := network.published()
+	for i := 1; i < len(requests); i++ {
+		previous, current := requests[i-1].PayOut[0], requests[i].PayOut[0]
+		assert.True(t, current.Timestamp.AsTime().Before(previous.Expiration.AsTime()),
+			"quotes must be published before the previous ones expire")
+	}
+}
+
+func TestPublisher_RetriesFailures(t *testing.T) {
+	network := &paymentNetwork{errs: []error{
+		connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+		connect.NewError(connect.CodeUnavailable, errors.New("unavailable")),
+	}}
+	source := &rateSource{rate: Rate{Value: decimal(92, -2)}}
+
+	var mu sync.Mutex
+	var errs []error
+	publisher, err := NewPaymentPublisher(network, []Market{eurMarket(source)},
+		WithRetryBackoff(10*time.Millisecond),
+		WithErrorHook(func(_ context.Context, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	startPublisher(t, publisher, err)
+
+	require.Eventually(t, func() bool { return len(network.published()) == 1 }, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, errs, 2)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(errs[0]))
+}
+
+func TestPublisher_StopsPublishingStaleRates(t *testing.T) {
+	network := &paymentNetwork{}
+	fresh := &rateSource{rate: Rate{Value: decimal(92, -2)}}
+	stale := &rateSource{rate: Rate{Value: decimal(18, -1), Time: time.Now().Add(-time.Hour)}}
+	gbp := eurMarket(stale)
+	gbp.Currency = "GBP"
+
+	var mu sync.Mutex
+	var errs []error
+	publisher, err := NewPaymentPublisher(network, []Market{eurMarket(fresh), gbp},
+		WithRefreshInterval(10*time.Millisecond),
+		WithMaxRateAge(50*time.Millisecond),
+		WithErrorHook(func(_ context.Context, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+	)
+	startPublisher(t, publisher, err)
+
+	require.Eventually(t, func() bool { return len(network.published()) == 1 }, time.Second, 5*time.Millisecond)
+	require.Len(t, network.published()[0].PayOut, 1)
+	assert.Equal(t, "EUR", network.published()[0].PayOut[0].Currency)
+
+	// The source fails, the last rate is used until it gets stale
+	fresh.set(Rate{}, errors.New("feed is down"))
+	time.Sleep(150 * time.Millisecond)
+	assert.Len(t, network.published(), 1)
+
+	mu.Lock()
+	defer mu.Unlock()
+	var staleErrs int
+	for _, err := range errs {
+		if errors.Is(err, ErrStaleRate) {
+			staleErrs++
+		}
+	}
+	assert.Equal(t, 2, staleErrs, "stale rates are reported once")
+}
+
+func TestPaymentIntentPublisher_PublishesPayInQuotes(t *testing.T) {
+	network := &paymentIntentNetwork{requests: make(chan *payment_intent.UpdateQuoteRequest, 10)}
+	publisher, err := NewPaymentIntentPublisher(network, []Market{
+		eurMarket(&rateSource{rate: Rate{Value: decimal(92, -2)}}),
+	})
+	startPublisher(t, publisher, err)
+
+	select {
+	case req := <-network.requests:
+		require.Len(t, req.PaymentIntentQuotes, 1)
+		quote := req.PaymentIntentQuotes[0]
+		assert.Equal(t, "EUR", quote.Currency)
+		require.Len(t, quote.Bands, 2)
+		assert.Contains(t, quote.Bands[0].ClientQuoteId, PayIn.String())
+	case <-time.After(time.Second):
+		t.Fatal("quotes were not published")
+	}
+}
+
+func TestNewPublisher_Validation(t *testing.T) {
+	source := &rateSource{}
+	noBands := eurMarket(source)
+	noBands.Bands = nil
+
+	tests := map[string]struct {
+		markets []Market
+		opts    []Option
+		err     error
+	}{
+		"no markets":       {err: ErrNoMarkets},
+		"no bands":         {markets: []Market{noBands}, err: ErrInvalidMarket},
+		"duplicate market": {markets: []Market{eurMarket(source), eurMarket(source)}, err: ErrDuplicateMarket},
+		"refresh ahead longer than the TTL": {
+			markets: []Market{eurMarket(source)},
+			opts:    []Option{WithQuoteTTL(time.Second), WithRefreshAhead(2 * time.Second)},
+			err:     ErrInvalidSchedule,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPaymentPublisher(&paymentNetwork{}, tt.markets, tt.opts...)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestApplySpread(t *testing.T) {
+	tests := map[string]struct {
+		rate     *common.Decimal
+		bps      int64
+		expected *common.Decimal
+	}{
+		"no spread":       {rate: decimal(92, -2), expected: decimal(92, -2)},
+		"positive spread": {rate: decimal(54321, -4), bps: 50, expected: decimal(54592605, -7)},
+		"negative spread": {rate: decimal(2, 0), bps: -2500, expected: decimal(15, -1)},
+		"whole result":    {rate: decimal(1, 0), bps: 10000, expected: decimal(2, 0)},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := applySpread(tt.rate, tt.bps)
+			require.NoError(t, err)
+			assertDecimal(t, tt.expected, got)
+		})
+	}
+
+	_, err := applySpread(decimal(1<<62, 0), 50)
+	assert.ErrorIs(t, err, ErrRateOverflow)
+}